@@ -3,6 +3,7 @@ package github
 import (
 	"context"
 
+	"github.com/fanatic/team-exporter/identity"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -11,50 +12,78 @@ type GitHubExporter struct {
 	Token            string
 	OrganizationName string
 	baseURL          string
+	identities       *identity.Mapper
+	privacy          identity.Privacy
 	resultCache      *Query
 }
 
-func New(baseURL, token, organizationName string) (*GitHubExporter, error) {
+// New exports the members of the organization, mapping their logins to people
+// with identities and labelling them as privacy allows
+func New(baseURL, token, organizationName string, identities *identity.Mapper, privacy identity.Privacy) (*GitHubExporter, error) {
+	if err := privacy.Validate(); err != nil {
+		return nil, err
+	}
+
 	metrics := map[string]*prometheus.Desc{}
 	metrics["UserCommitComments"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "github", "user_commit_comments"),
 		"Total number of user commit comments",
-		[]string{"user"}, nil,
+		[]string{"user", "person", "team"}, nil,
 	)
 	metrics["UserIssues"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "github", "user_issues"),
 		"Total number of user issues",
-		[]string{"user"}, nil,
+		[]string{"user", "person", "team"}, nil,
 	)
 	metrics["UserIssueComments"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "github", "user_issue_comments"),
 		"Total number of user issue comments",
-		[]string{"user"}, nil,
+		[]string{"user", "person", "team"}, nil,
 	)
 	metrics["UserPullRequests"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "github", "user_pull_requests"),
 		"Total number of user pull requests",
-		[]string{"user"}, nil,
+		[]string{"user", "person", "team"}, nil,
 	)
 	metrics["UserCommitContributions"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "github", "user_commit_contributions"),
 		"Total number of user commit contributions",
-		[]string{"user"}, nil,
+		[]string{"user", "person", "team"}, nil,
 	)
 	metrics["UserIssueContributions"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "github", "user_issue_contributions"),
 		"Total number of user issue contributions",
-		[]string{"user"}, nil,
+		[]string{"user", "person", "team"}, nil,
 	)
 	metrics["UserPullRequestContributions"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "github", "user_pull_request_contributions"),
 		"Total number of user pull request contributions",
-		[]string{"user"}, nil,
+		[]string{"user", "person", "team"}, nil,
 	)
 	metrics["UserPullRequestReviewContributions"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "github", "user_pull_request_review_contributions"),
 		"Total number of user pull request review contributions",
-		[]string{"user"}, nil,
+		[]string{"user", "person", "team"}, nil,
+	)
+	metrics["TeamCommitContributions"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "github", "team_commit_contributions"),
+		"Total number of commit contributions of the team members",
+		[]string{"team"}, nil,
+	)
+	metrics["TeamIssueContributions"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "github", "team_issue_contributions"),
+		"Total number of issue contributions of the team members",
+		[]string{"team"}, nil,
+	)
+	metrics["TeamPullRequestContributions"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "github", "team_pull_request_contributions"),
+		"Total number of pull request contributions of the team members",
+		[]string{"team"}, nil,
+	)
+	metrics["TeamPullRequestReviewContributions"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "github", "team_pull_request_review_contributions"),
+		"Total number of pull request review contributions of the team members",
+		[]string{"team"}, nil,
 	)
 	metrics["RepoOpenIssues"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "github", "repo_open_issues"),
@@ -107,6 +136,8 @@ func New(baseURL, token, organizationName string) (*GitHubExporter, error) {
 		Token:            token,
 		baseURL:          baseURL,
 		OrganizationName: organizationName,
+		identities:       identities,
+		privacy:          privacy,
 	}
 
 	// Fetch once so any bugs are triggered on startup
@@ -138,16 +169,38 @@ func (e *GitHubExporter) Collect(ch chan<- prometheus.Metric) {
 	ch <- prometheus.MustNewConstMetric(e.Metrics["Reset"], prometheus.GaugeValue, float64(q.RateLimit.ResetAt.Unix()))
 
 	// User Stats
+	users := e.privacy.NewSeries()
+	commits, issues, pullRequests, reviews := e.privacy.NewSums(), e.privacy.NewSums(), e.privacy.NewSums(), e.privacy.NewSums()
 	for _, member := range q.Organization.MembersWithRole.Nodes {
-		ch <- prometheus.MustNewConstMetric(e.Metrics["UserCommitComments"], prometheus.GaugeValue, float64(member.CommitComments.TotalCount), member.Login)
-		ch <- prometheus.MustNewConstMetric(e.Metrics["UserIssues"], prometheus.GaugeValue, float64(member.Issues.TotalCount), member.Login)
-		ch <- prometheus.MustNewConstMetric(e.Metrics["UserIssueComments"], prometheus.GaugeValue, float64(member.IssueComments.TotalCount), member.Login)
-		ch <- prometheus.MustNewConstMetric(e.Metrics["UserPullRequests"], prometheus.GaugeValue, float64(member.PullRequests.TotalCount), member.Login)
-
-		ch <- prometheus.MustNewConstMetric(e.Metrics["UserCommitContributions"], prometheus.GaugeValue, float64(member.ContributionsCollection.TotalCommitContributions), member.Login)
-		ch <- prometheus.MustNewConstMetric(e.Metrics["UserIssueContributions"], prometheus.GaugeValue, float64(member.ContributionsCollection.TotalIssueContributions), member.Login)
-		ch <- prometheus.MustNewConstMetric(e.Metrics["UserPullRequestContributions"], prometheus.GaugeValue, float64(member.ContributionsCollection.TotalPullRequestContributions), member.Login)
-		ch <- prometheus.MustNewConstMetric(e.Metrics["UserPullRequestReviewContributions"], prometheus.GaugeValue, float64(member.ContributionsCollection.TotalPullRequestReviewContributions), member.Login)
+		person, team := e.identities.Lookup(identity.GitHub, member.Login)
+		users.Add(e.Metrics["UserCommitComments"], prometheus.GaugeValue, float64(member.CommitComments.TotalCount), member.Login, person, identity.UserLabel, identity.PersonLabel, team)
+		users.Add(e.Metrics["UserIssues"], prometheus.GaugeValue, float64(member.Issues.TotalCount), member.Login, person, identity.UserLabel, identity.PersonLabel, team)
+		users.Add(e.Metrics["UserIssueComments"], prometheus.GaugeValue, float64(member.IssueComments.TotalCount), member.Login, person, identity.UserLabel, identity.PersonLabel, team)
+		users.Add(e.Metrics["UserPullRequests"], prometheus.GaugeValue, float64(member.PullRequests.TotalCount), member.Login, person, identity.UserLabel, identity.PersonLabel, team)
+
+		users.Add(e.Metrics["UserCommitContributions"], prometheus.GaugeValue, float64(member.ContributionsCollection.TotalCommitContributions), member.Login, person, identity.UserLabel, identity.PersonLabel, team)
+		users.Add(e.Metrics["UserIssueContributions"], prometheus.GaugeValue, float64(member.ContributionsCollection.TotalIssueContributions), member.Login, person, identity.UserLabel, identity.PersonLabel, team)
+		users.Add(e.Metrics["UserPullRequestContributions"], prometheus.GaugeValue, float64(member.ContributionsCollection.TotalPullRequestContributions), member.Login, person, identity.UserLabel, identity.PersonLabel, team)
+		users.Add(e.Metrics["UserPullRequestReviewContributions"], prometheus.GaugeValue, float64(member.ContributionsCollection.TotalPullRequestReviewContributions), member.Login, person, identity.UserLabel, identity.PersonLabel, team)
+
+		commits.AddTeam(float64(member.ContributionsCollection.TotalCommitContributions), member.Login, team)
+		issues.AddTeam(float64(member.ContributionsCollection.TotalIssueContributions), member.Login, team)
+		pullRequests.AddTeam(float64(member.ContributionsCollection.TotalPullRequestContributions), member.Login, team)
+		reviews.AddTeam(float64(member.ContributionsCollection.TotalPullRequestReviewContributions), member.Login, team)
+	}
+	users.Collect(ch)
+
+	// Team Stats
+	teamMetrics := map[string]*identity.Sums{
+		"TeamCommitContributions":            commits,
+		"TeamIssueContributions":             issues,
+		"TeamPullRequestContributions":       pullRequests,
+		"TeamPullRequestReviewContributions": reviews,
+	}
+	for name, sums := range teamMetrics {
+		sums.Each(func(v float64, labels ...string) {
+			ch <- prometheus.MustNewConstMetric(e.Metrics[name], prometheus.GaugeValue, v, labels...)
+		})
 	}
 
 	// Repository Stats
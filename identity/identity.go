@@ -0,0 +1,144 @@
+package identity
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Sources whose user labels are mapped to people
+const (
+	GitHub        = "github"
+	Trello        = "trello"
+	OpsGenie      = "opsgenie"
+	StackOverflow = "stackoverflow"
+)
+
+// Person lists the identities of one person per source, like their GitHub
+// login, Trello username, OpsGenie email and Stack Overflow user id
+type Person struct {
+	Name       string              `json:"name"`
+	Team       string              `json:"team"`
+	Identities map[string][]string `json:"identities"`
+}
+
+// Rule maps identities not listed for any person. An identity at EmailDomain
+// maps to the part before the @, an identity matching Pattern maps to Person
+// with submatches expanded like $1. A rule without Source applies to all.
+type Rule struct {
+	Source      string `json:"source"`
+	EmailDomain string `json:"emailDomain"`
+	Pattern     string `json:"pattern"`
+	Person      string `json:"person"`
+	Team        string `json:"team"`
+
+	pattern *regexp.Regexp
+}
+
+// File is the roster of people and teams together with the rules for
+// identities not listed in it
+type File struct {
+	People []Person `json:"people"`
+	Teams  []Team   `json:"teams"`
+	Rules  []Rule   `json:"rules"`
+}
+
+// Mapper looks up the canonical person and team of an identity. A nil Mapper
+// maps nothing, so exporters can use it unconfigured.
+type Mapper struct {
+	people map[string]map[string]*Person
+	teams  map[string]string
+	rules  []Rule
+}
+
+// Load reads the mapping from a JSON file, an empty path maps nothing
+func Load(path string) (*Mapper, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := File{}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("identity: %s: %v", path, err)
+	}
+	return New(f)
+}
+
+func New(f File) (*Mapper, error) {
+	m := &Mapper{people: map[string]map[string]*Person{}, teams: map[string]string{}}
+	for _, t := range f.Teams {
+		for _, member := range t.Members {
+			m.teams[member] = t.Name
+		}
+	}
+	for i := range f.People {
+		p := &f.People[i]
+		if p.Team == "" {
+			p.Team = m.teams[p.Name]
+		}
+		m.teams[p.Name] = p.Team
+		for source, ids := range p.Identities {
+			if m.people[source] == nil {
+				m.people[source] = map[string]*Person{}
+			}
+			for _, id := range ids {
+				m.people[source][strings.ToLower(id)] = p
+			}
+		}
+	}
+	for _, r := range f.Rules {
+		if r.Pattern != "" {
+			pattern, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("identity: rule pattern %q: %v", r.Pattern, err)
+			}
+			r.pattern = pattern
+		}
+		m.rules = append(m.rules, r)
+	}
+	return m, nil
+}
+
+// Lookup returns the person and team of an identity of the source, or empty
+// strings when it isn't mapped
+func (m *Mapper) Lookup(source, id string) (person, team string) {
+	if m == nil || id == "" {
+		return "", ""
+	}
+	if p, ok := m.people[source][strings.ToLower(id)]; ok {
+		return p.Name, p.Team
+	}
+	for _, r := range m.rules {
+		if r.Source != "" && r.Source != source {
+			continue
+		}
+		if person, ok := r.match(id); ok {
+			// Listed people keep their own team
+			if t, ok := m.teams[person]; ok && t != "" {
+				return person, t
+			}
+			return person, r.Team
+		}
+	}
+	return "", ""
+}
+
+func (r Rule) match(id string) (string, bool) {
+	if r.EmailDomain != "" {
+		at := strings.LastIndex(id, "@")
+		if at > 0 && strings.EqualFold(id[at+1:], r.EmailDomain) {
+			return strings.ToLower(id[:at]), true
+		}
+	}
+	if r.pattern != nil {
+		if sub := r.pattern.FindStringSubmatchIndex(id); sub != nil {
+			return string(r.pattern.ExpandString(nil, r.Person, id, sub)), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,149 @@
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Privacy modes for user labels
+const (
+	// Keep exports user labels as they are
+	Keep = ""
+	// Hash replaces user labels with a keyed hash, so series of a user stay
+	// apart without revealing who it is
+	Hash = "hash"
+	// Drop empties user labels and sums the series over the remaining labels
+	Drop = "drop"
+	// Aggregate exports no per-user series, only team series
+	Aggregate = "aggregate"
+)
+
+// Privacy is how a source exports labels identifying users. Sums over users,
+// like team series, are only exported for groups of at least MinGroupSize
+// users unless labels are kept, as hashed series still carry their team and a
+// team sum of a single user would equal that user's series.
+type Privacy struct {
+	Mode         string
+	Salt         string
+	MinGroupSize int
+}
+
+func (p Privacy) Validate() error {
+	switch p.Mode {
+	case Keep, Drop, Aggregate:
+	case Hash:
+		if p.Salt == "" {
+			return fmt.Errorf("identity: privacy mode hash needs a salt")
+		}
+	default:
+		return fmt.Errorf("identity: unknown privacy mode %q", p.Mode)
+	}
+	return nil
+}
+
+// NewSums returns sums skipping groups too small for the privacy mode
+func (p Privacy) NewSums() *Sums {
+	if p.Mode == Keep {
+		return newSums(0)
+	}
+	return newSums(p.MinGroupSize)
+}
+
+func (p Privacy) hash(v string) string {
+	mac := hmac.New(sha256.New, []byte(p.Salt))
+	mac.Write([]byte(v))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// Series buffers per-user series of a Collect so the privacy mode applies to
+// them consistently
+type Series struct {
+	privacy Privacy
+	descs   []*prometheus.Desc
+	types   map[*prometheus.Desc]prometheus.ValueType
+	sums    map[*prometheus.Desc]*Sums
+}
+
+func (p Privacy) NewSeries() *Series {
+	return &Series{privacy: p, types: map[*prometheus.Desc]prometheus.ValueType{}, sums: map[*prometheus.Desc]*Sums{}}
+}
+
+// Placeholders marking the positions of the labels identifying a user in the
+// labels passed to Series, so each label is named rather than counted
+const (
+	// UserLabel is replaced by the user of the source, like a login or user id
+	UserLabel = "\x00user"
+	// PersonLabel is replaced by the name of the user, like the canonical
+	// person or a display name
+	PersonLabel = "\x00person"
+)
+
+// Add records a series of the user. The labels hold UserLabel and optionally
+// PersonLabel where the user and person values belong.
+func (s *Series) Add(desc *prometheus.Desc, t prometheus.ValueType, value float64, user, person string, labels ...string) {
+	if s.privacy.Mode == Aggregate {
+		return
+	}
+	labels = append([]string{}, labels...)
+	found := false
+	for i, l := range labels {
+		switch l {
+		case UserLabel:
+			labels[i], found = s.privacy.label(user), true
+		case PersonLabel:
+			labels[i] = s.privacy.label(person)
+		}
+	}
+	if !found {
+		panic("identity: series labels without UserLabel")
+	}
+	if s.sums[desc] == nil {
+		s.descs = append(s.descs, desc)
+		s.types[desc] = t
+		// Only dropped labels sum several users, other series are of one user each
+		if s.privacy.Mode == Drop {
+			s.sums[desc] = s.privacy.NewSums()
+		} else {
+			s.sums[desc] = newSums(0)
+		}
+	}
+	s.sums[desc].Add(value, user, labels...)
+}
+
+// Info records an info series naming the user, which is only exported as is
+func (s *Series) Info(desc *prometheus.Desc, user, name string, labels ...string) {
+	if s.privacy.Mode != Keep {
+		return
+	}
+	s.Add(desc, prometheus.GaugeValue, 1, user, name, labels...)
+}
+
+// label returns the value of a label identifying a user under the privacy mode
+func (p Privacy) label(v string) string {
+	switch {
+	case p.Mode == Hash && v != "":
+		return p.hash(v)
+	case p.Mode == Drop:
+		return ""
+	}
+	return v
+}
+
+// Collect sends the series, summed over users whose labels were dropped
+func (s *Series) Collect(ch chan<- prometheus.Metric) {
+	s.each(func(desc *prometheus.Desc, t prometheus.ValueType, v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, t, v, labels...)
+	})
+}
+
+func (s *Series) each(f func(desc *prometheus.Desc, t prometheus.ValueType, v float64, labels ...string)) {
+	for _, desc := range s.descs {
+		s.sums[desc].Each(func(v float64, labels ...string) {
+			f(desc, s.types[desc], v, labels...)
+		})
+	}
+}
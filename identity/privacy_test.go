@@ -0,0 +1,116 @@
+package identity
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSeries(t *testing.T) {
+	salted := Privacy{Mode: Hash, Salt: "secret"}
+	tests := []struct {
+		name    string
+		privacy Privacy
+		want    map[string]float64
+	}{
+		{"keep", Privacy{}, map[string]float64{"x,a,Ann": 1, "x,b,Bob": 2, "y,c,": 4}},
+		{"hash", salted, map[string]float64{
+			"x," + salted.hash("a") + "," + salted.hash("Ann"): 1,
+			"x," + salted.hash("b") + "," + salted.hash("Bob"): 2,
+			"y," + salted.hash("c") + ",":                      4,
+		}},
+		{"drop", Privacy{Mode: Drop}, map[string]float64{"x,,": 3, "y,,": 4}},
+		{"drop below minimum group size", Privacy{Mode: Drop, MinGroupSize: 2}, map[string]float64{"x,,": 3}},
+		{"hash ignores minimum group size", Privacy{Mode: Hash, Salt: "secret", MinGroupSize: 2}, map[string]float64{
+			"x," + salted.hash("a") + "," + salted.hash("Ann"): 1,
+			"x," + salted.hash("b") + "," + salted.hash("Bob"): 2,
+			"y," + salted.hash("c") + ",":                      4,
+		}},
+		{"aggregate", Privacy{Mode: Aggregate}, map[string]float64{}},
+	}
+
+	desc := prometheus.NewDesc("test_series", "Test series", []string{"board", "user", "person"}, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.privacy.NewSeries()
+			s.Add(desc, prometheus.GaugeValue, 1, "a", "Ann", "x", UserLabel, PersonLabel)
+			s.Add(desc, prometheus.GaugeValue, 2, "b", "Bob", "x", UserLabel, PersonLabel)
+			s.Add(desc, prometheus.GaugeValue, 4, "c", "", "y", UserLabel, PersonLabel)
+
+			got := map[string]float64{}
+			s.each(func(_ *prometheus.Desc, _ prometheus.ValueType, v float64, labels ...string) {
+				got[strings.Join(labels, ",")] = v
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeriesInfo(t *testing.T) {
+	desc := prometheus.NewDesc("test_info", "Test info", []string{"user_id", "display_name"}, nil)
+	for _, mode := range []string{Keep, Hash, Drop, Aggregate} {
+		s := Privacy{Mode: mode, Salt: "secret"}.NewSeries()
+		s.Info(desc, "1", "Ann", UserLabel, PersonLabel)
+
+		got := 0
+		s.each(func(_ *prometheus.Desc, _ prometheus.ValueType, _ float64, _ ...string) { got++ })
+		if want := map[string]int{Keep: 1}[mode]; got != want {
+			t.Errorf("mode %q: got %d info series, want %d", mode, got, want)
+		}
+	}
+}
+
+func TestSums(t *testing.T) {
+	tests := []struct {
+		name    string
+		privacy Privacy
+		want    map[string]float64
+	}{
+		{"keep", Privacy{MinGroupSize: 2}, map[string]float64{"x,platform": 3, "x,sre": 4}},
+		{"hash", Privacy{Mode: Hash, Salt: "secret", MinGroupSize: 2}, map[string]float64{"x,platform": 3}},
+		{"aggregate", Privacy{Mode: Aggregate, MinGroupSize: 2}, map[string]float64{"x,platform": 3}},
+		{"drop", Privacy{Mode: Drop, MinGroupSize: 3}, map[string]float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.privacy.NewSums()
+			s.AddTeam(1, "a", "platform", "x")
+			s.AddTeam(2, "b", "platform", "x")
+			s.AddTeam(4, "c", "sre", "x")
+			s.AddTeam(8, "d", "", "x")
+
+			got := map[string]float64{}
+			s.Each(func(v float64, labels ...string) {
+				got[strings.Join(labels, ",")] = v
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrivacyValidate(t *testing.T) {
+	tests := []struct {
+		privacy Privacy
+		valid   bool
+	}{
+		{Privacy{}, true},
+		{Privacy{Mode: Hash, Salt: "secret"}, true},
+		{Privacy{Mode: Hash}, false},
+		{Privacy{Mode: Drop}, true},
+		{Privacy{Mode: Aggregate}, true},
+		{Privacy{Mode: "anonymize"}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.privacy.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v: got error %v", tt.privacy, err)
+		}
+	}
+}
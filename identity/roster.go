@@ -0,0 +1,55 @@
+package identity
+
+import "strings"
+
+// Team lists the people of a team in the roster, as an alternative to setting
+// the team of each person
+type Team struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+// Sums adds up per-user values by labels, for exporters to export team series
+// next to the per-user ones. Groups with fewer than min users are skipped.
+type Sums struct {
+	min    int
+	labels map[string][]string
+	values map[string]float64
+	users  map[string]map[string]bool
+	order  []string
+}
+
+func newSums(min int) *Sums {
+	return &Sums{min: min, labels: map[string][]string{}, values: map[string]float64{}, users: map[string]map[string]bool{}}
+}
+
+// AddTeam counts the value of the user towards the team and labels, skipping
+// users without a team. The team is exported as the last label.
+func (s *Sums) AddTeam(value float64, user, team string, labels ...string) {
+	if team == "" {
+		return
+	}
+	s.Add(value, user, append(append([]string{}, labels...), team)...)
+}
+
+// Add counts the value of the user towards the labels
+func (s *Sums) Add(value float64, user string, labels ...string) {
+	key := strings.Join(labels, "\x00")
+	if _, ok := s.labels[key]; !ok {
+		s.labels[key] = labels
+		s.users[key] = map[string]bool{}
+		s.order = append(s.order, key)
+	}
+	s.values[key] += value
+	s.users[key][user] = true
+}
+
+// Each calls f with every sum of a large enough group and its labels
+func (s *Sums) Each(f func(value float64, labels ...string)) {
+	for _, key := range s.order {
+		if len(s.users[key]) < s.min {
+			continue
+		}
+		f(s.values[key], s.labels[key]...)
+	}
+}
@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/fanatic/team-exporter/github"
+	"github.com/fanatic/team-exporter/identity"
 	"github.com/fanatic/team-exporter/opsgenie"
 	"github.com/fanatic/team-exporter/stackoverflow"
 	"github.com/fanatic/team-exporter/trello"
@@ -20,28 +24,92 @@ func main() {
 
 	fetchers := []Fetcher{}
 
-	ghExporter, err := github.New(os.Getenv("GITHUB_BASE_URL"), os.Getenv("GITHUB_TOKEN"), os.Getenv("GITHUB_ORGANIZATION"))
+	identities, err := identity.Load(os.Getenv("IDENTITY_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ghExporter, err := github.New(os.Getenv("GITHUB_BASE_URL"), os.Getenv("GITHUB_TOKEN"), os.Getenv("GITHUB_ORGANIZATION"), identities, getenvPrivacy("GITHUB"))
 	if err != nil {
 		log.Fatal(err)
 	}
 	prometheus.MustRegister(ghExporter)
 	fetchers = append(fetchers, ghExporter)
 
-	trelloExporter, err := trello.New(os.Getenv("TRELLO_APP_KEY"), os.Getenv("TRELLO_TOKEN"))
+	wipLimits, err := trello.ParseWIPLimits(getenvList("TRELLO_WIP_LIMITS"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	trelloExporter, err := trello.New(os.Getenv("TRELLO_APP_KEY"), os.Getenv("TRELLO_TOKEN"), trello.Config{
+		StateFile:           os.Getenv("TRELLO_STATE_FILE"),
+		Boards:              getenvList("TRELLO_BOARDS"),
+		Organizations:       getenvList("TRELLO_ORGANIZATIONS"),
+		BoardNames:          os.Getenv("TRELLO_BOARD_NAMES"),
+		ExcludeBoards:       getenvList("TRELLO_EXCLUDE_BOARDS"),
+		ExcludeBoardNames:   os.Getenv("TRELLO_EXCLUDE_BOARD_NAMES"),
+		IgnoreClosedBoards:  getenvBool("TRELLO_IGNORE_CLOSED_BOARDS", true),
+		IgnoreArchivedLists: getenvBool("TRELLO_IGNORE_ARCHIVED_LISTS", true),
+		DueSoonDays:         getenvInt("TRELLO_DUE_SOON_DAYS", 7),
+		NumberFields:        getenvList("TRELLO_NUMBER_FIELDS"),
+		DropdownFields:      getenvList("TRELLO_DROPDOWN_FIELDS"),
+		WIPLimits:           wipLimits,
+		Concurrency:         getenvInt("TRELLO_CONCURRENCY", 4),
+		WebhookURL:          os.Getenv("TRELLO_WEBHOOK_URL"),
+		AppSecret:           os.Getenv("TRELLO_APP_SECRET"),
+		Identities:          identities,
+		Privacy:             getenvPrivacy("TRELLO"),
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
 	prometheus.MustRegister(trelloExporter)
 	fetchers = append(fetchers, trelloExporter)
 
-	opsgenieExporter, err := opsgenie.New(os.Getenv("OPSGENIE_APIKEY"), os.Getenv("OPSGENIE_SCHEDULE"))
+	alertPriorities := getenvList("OPSGENIE_ALERT_PRIORITIES")
+	if len(alertPriorities) == 0 {
+		alertPriorities = []string{"P1", "P2", "P3", "P4", "P5"}
+	}
+	timezone, err := time.LoadLocation(os.Getenv("OPSGENIE_TIMEZONE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	opsgenieExporter, err := opsgenie.New(os.Getenv("OPSGENIE_APIKEY"), opsgenie.Config{
+		BaseURL:         os.Getenv("OPSGENIE_BASE_URL"),
+		Schedules:       getenvList("OPSGENIE_SCHEDULE"),
+		Teams:           getenvList("OPSGENIE_TEAMS"),
+		LookaheadDays:   getenvInt("OPSGENIE_LOOKAHEAD_DAYS", 14),
+		AlertPriorities: alertPriorities,
+		AlertTeams:      getenvList("OPSGENIE_ALERT_TEAMS"),
+		AlertTags:       getenvList("OPSGENIE_ALERT_TAGS"),
+		AlertSources:    getenvList("OPSGENIE_ALERT_SOURCES"),
+		AlertWindow:     getenvDuration("OPSGENIE_ALERT_WINDOW", 7*24*time.Hour),
+		PostmortemTag:   os.Getenv("OPSGENIE_POSTMORTEM_TAG"),
+		Timezone:        timezone,
+		WorkdayStart:    getenvInt("OPSGENIE_WORKDAY_START", 9),
+		WorkdayEnd:      getenvInt("OPSGENIE_WORKDAY_END", 17),
+		NightStart:      getenvInt("OPSGENIE_NIGHT_START", 22),
+		NightEnd:        getenvInt("OPSGENIE_NIGHT_END", 7),
+		Identities:      identities,
+		Privacy:         getenvPrivacy("OPSGENIE"),
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
 	prometheus.MustRegister(opsgenieExporter)
 	fetchers = append(fetchers, opsgenieExporter)
 
-	stackOverflowExporter, err := stackoverflow.New(os.Getenv("STACKOVERFLOW_BASE_URL"), os.Getenv("STACKOVERFLOW_KEY"), os.Getenv("STACKOVERFLOW_TAG"))
+	stackOverflowExporter, err := stackoverflow.New(os.Getenv("STACKOVERFLOW_BASE_URL"), os.Getenv("STACKOVERFLOW_KEY"), stackoverflow.Config{
+		Team:        os.Getenv("STACKOVERFLOW_TEAM"),
+		AccessToken: os.Getenv("STACKOVERFLOW_ACCESS_TOKEN"),
+		Version:     os.Getenv("STACKOVERFLOW_API_VERSION"),
+		Tags:        getenvList("STACKOVERFLOW_TAG"),
+		Concurrency: getenvInt("STACKOVERFLOW_CONCURRENCY", 2),
+		MinQuota:    getenvInt("STACKOVERFLOW_MIN_QUOTA", 1000),
+		MaxPages:    getenvInt("STACKOVERFLOW_MAX_PAGES", 10),
+		TopWindow:   getenvDuration("STACKOVERFLOW_TOP_WINDOW", 0),
+		Identities:  identities,
+		Privacy:     getenvPrivacy("STACKOVERFLOW"),
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -51,12 +119,21 @@ func main() {
 	go PeriodicFetcher(fetchers)
 
 	http.Handle("/metrics", prometheus.Handler())
+	if os.Getenv("TRELLO_WEBHOOK_URL") != "" {
+		http.Handle("/trello/webhook", trelloExporter)
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "9000"
 	}
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Trello checks the callback URL when registering webhooks, which is answered once serving
+	go trelloExporter.EnsureWebhooks()
+	log.Fatal(http.Serve(listener, nil))
 }
 
 type Fetcher interface {
@@ -101,3 +178,52 @@ func concurrentFetcher(ctx context.Context, fetchers []Fetcher) {
 
 	wg.Wait()
 }
+
+// getenvList splits a comma separated environment variable, skipping empty entries
+func getenvList(key string) []string {
+	list := []string{}
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func getenvBool(key string, fallback bool) bool {
+	b, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return b
+}
+
+func getenvInt(key string, fallback int) int {
+	i, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return i
+}
+
+func getenvDuration(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return d
+}
+
+// getenvPrivacy reads the privacy setting of the source, like GITHUB_PRIVACY_MODE,
+// falling back to the global PRIVACY_MODE
+func getenvPrivacy(source string) identity.Privacy {
+	mode, ok := os.LookupEnv(source + "_PRIVACY_MODE")
+	if !ok {
+		mode = os.Getenv("PRIVACY_MODE")
+	}
+	return identity.Privacy{
+		Mode:         mode,
+		Salt:         os.Getenv("PRIVACY_SALT"),
+		MinGroupSize: getenvInt("PRIVACY_MIN_GROUP_SIZE", 5),
+	}
+}
@@ -0,0 +1,79 @@
+package opsgenie
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Alert states and the query selecting each of them
+var alertStates = []struct {
+	Name  string
+	Query string
+}{
+	{"unacked", "status:open AND acknowledged:false"},
+	{"acked", "status:open AND acknowledged:true"},
+	{"closed", "status:closed"},
+}
+
+// AlertGroup is the number of alerts in a state matching the labelled
+// priority, owning team, tag and integration source. Empty labels match any value.
+type AlertGroup struct {
+	State    string
+	Priority string
+	Team     string
+	Tag      string
+	Source   string
+	Query    string
+	Count    int
+}
+
+// alertGroups splits every alert state by each combination of the configured values
+func (c Config) alertGroups() []AlertGroup {
+	if len(c.AlertPriorities)+len(c.AlertTeams)+len(c.AlertTags)+len(c.AlertSources) == 0 {
+		return nil
+	}
+
+	groups := []AlertGroup{}
+	for _, state := range alertStates {
+		groups = append(groups, AlertGroup{State: state.Name, Query: state.Query})
+	}
+
+	split := func(values []string, field string, set func(g *AlertGroup, v string)) {
+		if len(values) == 0 {
+			return
+		}
+		split := []AlertGroup{}
+		for _, g := range groups {
+			for _, v := range values {
+				s := g
+				set(&s, v)
+				s.Query = fmt.Sprintf("%s AND %s:%s", s.Query, field, quote(v))
+				split = append(split, s)
+			}
+		}
+		groups = split
+	}
+	split(c.AlertPriorities, "priority", func(g *AlertGroup, v string) { g.Priority = v })
+	split(c.AlertTeams, "teams", func(g *AlertGroup, v string) { g.Team = v })
+	split(c.AlertTags, "tag", func(g *AlertGroup, v string) { g.Tag = v })
+	split(c.AlertSources, "integration.name", func(g *AlertGroup, v string) { g.Source = v })
+	return groups
+}
+
+// quote escapes a value for the alert search query syntax
+func quote(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+}
+
+func (e *OpsGenieExporter) GetAlertGroups(ctx context.Context) ([]AlertGroup, error) {
+	groups := e.config.alertGroups()
+	for i := range groups {
+		count, err := e.AlertCount(ctx, groups[i].Query)
+		if err != nil {
+			return nil, err
+		}
+		groups[i].Count = count
+	}
+	return groups, nil
+}
@@ -0,0 +1,89 @@
+package opsgenie
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fanatic/team-exporter/identity"
+)
+
+const DefaultBaseURL = "https://api.opsgenie.com"
+
+type Config struct {
+	// BaseURL of the API without version, like https://api.eu.opsgenie.com
+	BaseURL string
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+
+	// Schedules are exported by name, together with every schedule owned by
+	// one of Teams. When neither is set every schedule is exported.
+	Schedules []string
+	Teams     []string
+	// LookaheadDays is how far ahead schedules are checked for gaps and overrides
+	LookaheadDays int
+
+	// Alert counts are split by every combination of these values, a
+	// dimension without values is not split
+	AlertPriorities []string
+	AlertTeams      []string
+	AlertTags       []string
+	AlertSources    []string
+
+	// AlertWindow is how far back closed alerts are listed for response times
+	// and created alerts for on-call load
+	AlertWindow time.Duration
+
+	// PostmortemTag marks incidents whose postmortem is pending until the tag
+	// is removed, leave empty to not count pending postmortems
+	PostmortemTag string
+
+	// Alerts on weekdays between WorkdayStart and WorkdayEnd count as business
+	// hours, alerts between NightStart and NightEnd interrupt a night. Hours
+	// range from 0 to 24, the night may wrap past midnight.
+	Timezone     *time.Location
+	WorkdayStart int
+	WorkdayEnd   int
+	NightStart   int
+	NightEnd     int
+
+	// Identities maps OpsGenie usernames, which are emails, to people,
+	// Privacy is how usernames are exported
+	Identities *identity.Mapper
+	Privacy    identity.Privacy
+}
+
+// validate checks the working and night hours
+func (c Config) validate() error {
+	for _, h := range []int{c.WorkdayStart, c.WorkdayEnd, c.NightStart, c.NightEnd} {
+		if h < 0 || h > 24 {
+			return fmt.Errorf("opsgenie: invalid hour %d, expected 0 to 24", h)
+		}
+	}
+	if c.NightStart == 24 {
+		return fmt.Errorf("opsgenie: night start 24 should be 0")
+	}
+	if c.WorkdayStart >= c.WorkdayEnd {
+		return fmt.Errorf("opsgenie: workday start %d is not before its end %d", c.WorkdayStart, c.WorkdayEnd)
+	}
+	if c.NightStart%24 == c.NightEnd%24 {
+		return fmt.Errorf("opsgenie: night start %d and end %d are the same hour", c.NightStart, c.NightEnd)
+	}
+	return nil
+}
+
+// withDefaults fills in the API location and client when they are not configured
+func (c Config) withDefaults() Config {
+	if c.BaseURL == "" {
+		c.BaseURL = DefaultBaseURL
+	}
+	if !strings.Contains(c.BaseURL, "://") {
+		c.BaseURL = "https://" + c.BaseURL
+	}
+	c.BaseURL = strings.TrimSuffix(c.BaseURL, "/")
+	if c.HTTPClient == nil {
+		c.HTTPClient = http.DefaultClient
+	}
+	return c
+}
@@ -4,28 +4,103 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
 type Query struct {
-	WhosOnCall    string
+	OnCalls       []OnCall
+	Escalations   []EscalationOnCall
+	Rotations     []RotationOnCall
+	Handovers     []Handover
+	Coverage      []Coverage
 	UnAckedAlerts int
 	AckedAlerts   int
 	ClosedAlerts  int
+	AlertGroups   []AlertGroup
+	AckTimes      []*Histogram
+	CloseTimes    []*Histogram
+	Acknowledgers []Responder
+	OnCallLoads   []OnCallLoad
+	Incidents     []IncidentCount
+	Postmortems   []IncidentCount
+	Durations     []*Histogram
+	Heartbeats    []Heartbeat
+	Integrations  []Integration
 }
 
 func (e *OpsGenieExporter) Fetch(ctx context.Context) error {
 	log.WithFields(log.Fields{"ref": "opsgenie.fetch", "at": "start"}).Info()
 	startTime := time.Now()
 
-	oncall, err := e.GetOncalls(ctx)
+	q := &Query{}
+	since := startTime.Add(-e.config.AlertWindow)
+
+	teams, err := e.GetTeams(ctx)
+	if err != nil {
+		return err
+	}
+	teamNames := map[string]string{}
+	for _, t := range teams {
+		teamNames[t.ID] = t.Name
+	}
+	// Every alert created within the window counts towards on-call load, while
+	// response times cover the alerts closed within the window
+	alerts, err := e.ListAlerts(ctx, fmt.Sprintf("createdAt > %d", since.UnixMilli()))
+	if err != nil {
+		return err
+	}
+	closedAlerts, err := e.ListAlerts(ctx, fmt.Sprintf("status:closed AND updatedAt > %d", since.UnixMilli()))
+	if err != nil {
+		return err
+	}
+
+	schedules, err := e.GetSchedules(ctx)
 	if err != nil {
 		return err
 	}
+	for _, schedule := range schedules {
+		recipients, escalations, err := e.GetOncalls(ctx, schedule.ID)
+		if err != nil {
+			return err
+		}
+		for _, o := range escalations {
+			o.Schedule = schedule.Name
+			q.Escalations = append(q.Escalations, o)
+		}
+		if len(recipients) == 0 {
+			recipients = []string{"no-one"}
+		}
+		for _, r := range recipients {
+			q.OnCalls = append(q.OnCalls, OnCall{Schedule: schedule.Name, User: r})
+		}
+
+		timeline, err := e.GetTimeline(ctx, schedule.ID, since, timelineDays(e.config.AlertWindow, e.config.LookaheadDays))
+		if err != nil {
+			return err
+		}
+		coverage := timeline.coverage(startTime, startTime.AddDate(0, 0, e.config.LookaheadDays))
+		coverage.Schedule = schedule.Name
+		q.Coverage = append(q.Coverage, coverage)
+		q.OnCallLoads = append(q.OnCallLoads, e.onCallLoad(schedule, timeline, alerts)...)
+
+		rotations, handovers := timeline.current(startTime)
+		for _, r := range rotations {
+			r.Schedule = schedule.Name
+			q.Rotations = append(q.Rotations, r)
+		}
+		for _, h := range handovers {
+			h.Schedule = schedule.Name
+			q.Handovers = append(q.Handovers, h)
+		}
+	}
+
 	unacked, err := e.AlertCount(ctx, "status:open AND acknowledged:false")
 	if err != nil {
 		return err
@@ -39,29 +114,127 @@ func (e *OpsGenieExporter) Fetch(ctx context.Context) error {
 		return err
 	}
 
-	e.resultCache = &Query{
-		WhosOnCall:    oncall,
-		UnAckedAlerts: unacked,
-		AckedAlerts:   acked,
-		ClosedAlerts:  closed,
+	q.UnAckedAlerts = unacked
+	q.AckedAlerts = acked
+	q.ClosedAlerts = closed
+
+	q.AlertGroups, err = e.GetAlertGroups(ctx)
+	if err != nil {
+		return err
+	}
+
+	q.AckTimes, q.CloseTimes, q.Acknowledgers = responseTimes(closedAlerts, teamNames)
+
+	// The incident API is only available on some plans, which shouldn't cost the other metrics
+	q.Incidents, q.Durations, err = e.GetIncidents(ctx, since)
+	if err != nil {
+		log.WithFields(log.Fields{"ref": "opsgenie.fetch", "at": "incidents-error", "err": err}).Warn()
+	}
+	if e.config.PostmortemTag != "" {
+		q.Postmortems, err = e.GetPostmortemsPending(ctx)
+		if err != nil {
+			log.WithFields(log.Fields{"ref": "opsgenie.fetch", "at": "postmortems-error", "err": err}).Warn()
+		}
+	}
+
+	// Heartbeats and integrations need an API key with configuration access
+	q.Heartbeats, err = e.GetHeartbeats(ctx)
+	if err != nil {
+		log.WithFields(log.Fields{"ref": "opsgenie.fetch", "at": "heartbeats-error", "err": err}).Warn()
+	}
+	q.Integrations, err = e.GetIntegrations(ctx)
+	if err != nil {
+		log.WithFields(log.Fields{"ref": "opsgenie.fetch", "at": "integrations-error", "err": err}).Warn()
 	}
 
+	e.resultCache = q
+
 	log.WithFields(log.Fields{"ref": "opsgenie.collect", "at": "finish", "duration": time.Since(startTime)}).Info()
 	return nil
 }
 
-func (e *OpsGenieExporter) GetOncalls(ctx context.Context) (string, error) {
+type OnCall struct {
+	Schedule string
+	User     string
+}
+
+type RotationOnCall struct {
+	Schedule string
+	Rotation string
+	User     string
+}
+
+type Handover struct {
+	Schedule string
+	Rotation string
+	At       time.Time
+}
+
+// EscalationOnCall is a user on call through a level of an escalation in a schedule rotation
+type EscalationOnCall struct {
+	Schedule   string
+	Escalation string
+	Level      int
+	User       string
+}
+
+type Participant struct {
+	Name           string        `json:"name"`
+	Type           string        `json:"type"`
+	EscalationTime int           `json:"escalationTime"`
+	Participants   []Participant `json:"onCallParticipants"`
+}
+
+// users returns the users among the participant and everyone nested within it
+func (p Participant) users() []string {
+	if p.Type == "user" {
+		return []string{p.Name}
+	}
+	users := []string{}
+	for _, c := range p.Participants {
+		users = append(users, c.users()...)
+	}
+	return users
+}
+
+// GetOncalls returns everyone on call for the schedule, and those on call
+// through an escalation by level, ordered by the time each level is notified
+func (e *OpsGenieExporter) GetOncalls(ctx context.Context, scheduleID string) ([]string, []EscalationOnCall, error) {
 	result := struct {
-		Recipients []string `json:"onCallRecipients"`
+		Participants []Participant `json:"onCallParticipants"`
 	}{}
-	err := e.getRequest(ctx, fmt.Sprintf("/schedules/%s/on-calls?scheduleIdentifierType=name&flat=true", e.schedule), &result)
+	err := e.getRequest(ctx, fmt.Sprintf("/v2/schedules/%s/on-calls?scheduleIdentifierType=id", url.PathEscape(scheduleID)), &result)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
-	for _, r := range result.Recipients {
-		return r, nil
+
+	recipients := []string{}
+	seen := map[string]bool{}
+	escalations := []EscalationOnCall{}
+	for _, p := range result.Participants {
+		for _, user := range p.users() {
+			if !seen[user] {
+				seen[user] = true
+				recipients = append(recipients, user)
+			}
+		}
+		if p.Type != "escalation" {
+			continue
+		}
+		levels := append([]Participant{}, p.Participants...)
+		sort.SliceStable(levels, func(i, j int) bool { return levels[i].EscalationTime < levels[j].EscalationTime })
+		onLevel := map[EscalationOnCall]bool{}
+		for i, level := range levels {
+			for _, user := range level.users() {
+				o := EscalationOnCall{Escalation: p.Name, Level: i + 1, User: user}
+				if !onLevel[o] {
+					onLevel[o] = true
+					escalations = append(escalations, o)
+				}
+			}
+		}
 	}
-	return "no-one", err
+	return recipients, escalations, nil
 }
 
 func (e *OpsGenieExporter) AlertCount(ctx context.Context, query string) (int, error) {
@@ -71,7 +244,7 @@ func (e *OpsGenieExporter) AlertCount(ctx context.Context, query string) (int, e
 	v := url.Values{}
 	v.Set("query", query)
 
-	err := e.getRequest(ctx, "/alerts/count?"+v.Encode(), &result)
+	err := e.getRequest(ctx, "/v2/alerts/count?"+v.Encode(), &result)
 	if err != nil {
 		return 0, err
 	}
@@ -79,14 +252,14 @@ func (e *OpsGenieExporter) AlertCount(ctx context.Context, query string) (int, e
 }
 
 func (e *OpsGenieExporter) getRequest(ctx context.Context, path string, b interface{}) error {
-	//log.WithFields(log.Fields{"ref": "opsgenie.get-request", "at": "start", "url": "https://api.opsgenie.com/v2" + path}).Info()
+	//log.WithFields(log.Fields{"ref": "opsgenie.get-request", "at": "start", "url": e.config.BaseURL + path}).Info()
 
-	req, err := http.NewRequest("GET", "https://api.opsgenie.com/v2"+path, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", e.config.BaseURL+path, nil)
 	if err != nil {
 		return err
 	}
 	req.Header.Add("Authorization", "GenieKey "+e.apiKey)
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := e.config.HTTPClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -98,6 +271,15 @@ func (e *OpsGenieExporter) getRequest(ctx context.Context, path string, b interf
 		Took      float64         `json:"took"`
 		RequestID string          `json:"requestId"`
 	}{}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// Error bodies usually carry a message, but proxies may answer with anything
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if err := json.Unmarshal(body, &result); err != nil || result.Message == "" {
+			result.Message = strings.TrimSpace(string(body))
+		}
+		log.WithFields(log.Fields{"ref": "opsgenie.get-request", "at": "error", "status": resp.StatusCode, "message": result.Message, "request-id": result.RequestID}).Warn()
+		return fmt.Errorf("opsgenie: GET %s: %s: %s", path, resp.Status, result.Message)
+	}
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
 		return err
@@ -0,0 +1,41 @@
+package opsgenie
+
+import (
+	"context"
+)
+
+type Heartbeat struct {
+	Name      string `json:"name"`
+	Enabled   bool   `json:"enabled"`
+	Expired   bool   `json:"expired"`
+	OwnerTeam struct {
+		Name string `json:"name"`
+	} `json:"ownerTeam"`
+}
+
+type Integration struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+}
+
+func (e *OpsGenieExporter) GetHeartbeats(ctx context.Context) ([]Heartbeat, error) {
+	result := struct {
+		Heartbeats []Heartbeat `json:"heartbeats"`
+	}{}
+	err := e.getRequest(ctx, "/v2/heartbeats", &result)
+	if err != nil {
+		return nil, err
+	}
+	return result.Heartbeats, nil
+}
+
+func (e *OpsGenieExporter) GetIntegrations(ctx context.Context) ([]Integration, error) {
+	result := []Integration{}
+	err := e.getRequest(ctx, "/v2/integrations", &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,129 @@
+package opsgenie
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type Incident struct {
+	ID              string     `json:"id"`
+	TinyID          string     `json:"tinyId"`
+	Status          string     `json:"status"`
+	Priority        string     `json:"priority"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	ImpactStartDate *time.Time `json:"impactStartDate"`
+	ImpactEndDate   *time.Time `json:"impactEndDate"`
+	Tags            []string   `json:"tags"`
+}
+
+type IncidentCount struct {
+	Status   string
+	Priority string
+	Count    int
+}
+
+// ListIncidents pages through the incidents matching the query, newest first,
+// until they were created before since
+func (e *OpsGenieExporter) ListIncidents(ctx context.Context, query string, since time.Time) ([]Incident, error) {
+	incidents := []Incident{}
+	for offset := 0; offset < maxAlertOffset; offset += alertPageSize {
+		page := []Incident{}
+		v := url.Values{}
+		v.Set("query", query)
+		v.Set("offset", strconv.Itoa(offset))
+		v.Set("limit", strconv.Itoa(alertPageSize))
+		v.Set("sort", "createdAt")
+		v.Set("order", "desc")
+
+		err := e.getRequest(ctx, "/v1/incidents?"+v.Encode(), &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range page {
+			if i.CreatedAt.Before(since) {
+				return incidents, nil
+			}
+			incidents = append(incidents, i)
+		}
+		if len(page) < alertPageSize {
+			break
+		}
+	}
+	return incidents, nil
+}
+
+// GetIncidents counts open and resolved incidents by priority and aggregates
+// how long incidents created since the start of the window had an impact.
+// Resolved incidents are counted until they are closed.
+func (e *OpsGenieExporter) GetIncidents(ctx context.Context, since time.Time) (counts []IncidentCount, durations []*Histogram, err error) {
+	byStatus := map[[2]string]int{}
+	byPriority := map[string]*Histogram{}
+
+	for _, status := range []string{"open", "resolved", "closed"} {
+		// Every open and resolved incident is counted, closed ones only within the window
+		start := time.Time{}
+		if status == "closed" {
+			start = since
+		}
+		incidents, err := e.ListIncidents(ctx, "status:"+status, start)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, i := range incidents {
+			if status != "closed" {
+				byStatus[[2]string{status, i.Priority}]++
+			}
+			if i.CreatedAt.Before(since) || i.ImpactStartDate == nil || i.ImpactEndDate == nil {
+				continue
+			}
+			if byPriority[i.Priority] == nil {
+				byPriority[i.Priority] = &Histogram{Priority: i.Priority, Buckets: map[float64]uint64{}}
+			}
+			byPriority[i.Priority].observe(i.ImpactEndDate.Sub(*i.ImpactStartDate).Seconds())
+		}
+	}
+
+	for k, count := range byStatus {
+		counts = append(counts, IncidentCount{Status: k[0], Priority: k[1], Count: count})
+	}
+	for _, h := range byPriority {
+		durations = append(durations, h)
+	}
+	return counts, durations, nil
+}
+
+// GetPostmortemsPending counts resolved and closed incidents by priority which
+// still carry the postmortem tag. Teams tag incidents needing a postmortem and
+// remove the tag once it is done, however long ago the incident was.
+func (e *OpsGenieExporter) GetPostmortemsPending(ctx context.Context) ([]IncidentCount, error) {
+	incidents, err := e.ListIncidents(ctx, "tag:"+quote(e.config.PostmortemTag), time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	byPriority := map[string]int{}
+	for _, i := range incidents {
+		if i.Status == "open" || !hasTag(i.Tags, e.config.PostmortemTag) {
+			continue
+		}
+		byPriority[i.Priority]++
+	}
+
+	counts := []IncidentCount{}
+	for priority, count := range byPriority {
+		counts = append(counts, IncidentCount{Priority: priority, Count: count})
+	}
+	return counts, nil
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
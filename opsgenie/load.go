@@ -0,0 +1,107 @@
+package opsgenie
+
+import (
+	"time"
+)
+
+// OnCallLoad counts the alerts created while a user was on call for a schedule
+type OnCallLoad struct {
+	Schedule      string
+	User          string
+	BusinessHours int
+	OutOfHours    int
+	Nights        int
+}
+
+// businessHours reports whether t falls within working hours on a weekday
+func (c Config) businessHours(t time.Time) bool {
+	t = t.In(c.location())
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	return t.Hour() >= c.WorkdayStart && t.Hour() < c.WorkdayEnd
+}
+
+// night returns the date the night containing t started on, if t falls within
+// night hours. Nights usually wrap past midnight, like 22 to 7, but may also lie
+// within a single day, like 0 to 6.
+func (c Config) night(t time.Time) (string, bool) {
+	t = t.In(c.location())
+	if c.NightStart < c.NightEnd {
+		if t.Hour() >= c.NightStart && t.Hour() < c.NightEnd {
+			return t.Format("2006-01-02"), true
+		}
+		return "", false
+	}
+	switch {
+	case t.Hour() >= c.NightStart:
+		return t.Format("2006-01-02"), true
+	case t.Hour() < c.NightEnd:
+		return t.AddDate(0, 0, -1).Format("2006-01-02"), true
+	}
+	return "", false
+}
+
+func (c Config) location() *time.Location {
+	if c.Timezone == nil {
+		return time.UTC
+	}
+	return c.Timezone
+}
+
+// recipientsAt returns everyone on call across the rotations of the timeline at t
+func (t *Timeline) recipientsAt(at time.Time) []string {
+	recipients := []string{}
+	for _, r := range t.FinalTimeline.Rotations {
+		for _, p := range r.Periods {
+			if !p.StartDate.After(at) && p.EndDate.After(at) {
+				recipients = append(recipients, p.Recipient.Name)
+			}
+		}
+	}
+	return recipients
+}
+
+// onCallLoad attributes alerts to whoever was on call for a schedule owned by
+// one of the alert's teams. Schedules without an owning team receive every alert.
+func (e *OpsGenieExporter) onCallLoad(schedule Schedule, timeline *Timeline, alerts []Alert) []OnCallLoad {
+	loads := map[string]*OnCallLoad{}
+	nights := map[string]map[string]bool{}
+
+	for _, a := range alerts {
+		if schedule.OwnerTeam.ID != "" && !a.assignedTo(schedule.OwnerTeam.ID) {
+			continue
+		}
+
+		for _, user := range timeline.recipientsAt(a.CreatedAt) {
+			if loads[user] == nil {
+				loads[user] = &OnCallLoad{Schedule: schedule.Name, User: user}
+				nights[user] = map[string]bool{}
+			}
+			if e.config.businessHours(a.CreatedAt) {
+				loads[user].BusinessHours++
+			} else {
+				loads[user].OutOfHours++
+			}
+			if night, ok := e.config.night(a.CreatedAt); ok {
+				nights[user][night] = true
+			}
+		}
+	}
+
+	result := []OnCallLoad{}
+	for user, l := range loads {
+		l.Nights = len(nights[user])
+		result = append(result, *l)
+	}
+	return result
+}
+
+func (a Alert) assignedTo(teamID string) bool {
+	for _, t := range a.Teams {
+		if t.ID == teamID {
+			return true
+		}
+	}
+	return false
+}
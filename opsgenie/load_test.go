@@ -0,0 +1,62 @@
+package opsgenie
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNight(t *testing.T) {
+	wrapping := Config{NightStart: 22, NightEnd: 7}
+	early := Config{NightStart: 0, NightEnd: 6}
+	tests := []struct {
+		name   string
+		config Config
+		hour   int
+		want   string
+		ok     bool
+	}{
+		{"wrapping before midnight", wrapping, 23, "2024-03-05", true},
+		{"wrapping after midnight", wrapping, 3, "2024-03-04", true},
+		{"wrapping end", wrapping, 7, "", false},
+		{"wrapping day", wrapping, 12, "", false},
+		{"same day start", early, 0, "2024-03-05", true},
+		{"same day", early, 5, "2024-03-05", true},
+		{"same day end", early, 6, "", false},
+		{"same day evening", early, 23, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.config.night(time.Date(2024, 3, 5, tt.hour, 30, 0, 0, time.UTC))
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("got %q, %v, want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{"wrapping night", Config{WorkdayStart: 9, WorkdayEnd: 17, NightStart: 22, NightEnd: 7}, true},
+		{"same day night", Config{WorkdayStart: 9, WorkdayEnd: 17, NightStart: 0, NightEnd: 6}, true},
+		{"night until midnight", Config{WorkdayStart: 9, WorkdayEnd: 17, NightStart: 22, NightEnd: 24}, true},
+		{"hour out of range", Config{WorkdayStart: 9, WorkdayEnd: 25, NightStart: 22, NightEnd: 7}, false},
+		{"negative hour", Config{WorkdayStart: 9, WorkdayEnd: 17, NightStart: -1, NightEnd: 7}, false},
+		{"night start 24", Config{WorkdayStart: 9, WorkdayEnd: 17, NightStart: 24, NightEnd: 7}, false},
+		{"empty workday", Config{WorkdayStart: 17, WorkdayEnd: 9, NightStart: 22, NightEnd: 7}, false},
+		{"empty night", Config{WorkdayStart: 9, WorkdayEnd: 17, NightStart: 7, NightEnd: 7}, false},
+		{"whole day night", Config{WorkdayStart: 9, WorkdayEnd: 17, NightStart: 0, NightEnd: 24}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validate(); (err == nil) != tt.valid {
+				t.Errorf("got error %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
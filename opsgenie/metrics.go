@@ -2,44 +2,159 @@ package opsgenie
 
 import (
 	"context"
+	"strconv"
 
+	"github.com/fanatic/team-exporter/identity"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 type OpsGenieExporter struct {
 	Metrics     map[string]*prometheus.Desc
 	apiKey      string
-	schedule    string
+	config      Config
 	resultCache *Query
 }
 
-func New(apiKey, schedule string) (*OpsGenieExporter, error) {
+func New(apiKey string, config Config) (*OpsGenieExporter, error) {
 	metrics := map[string]*prometheus.Desc{}
 	metrics["WhosOnCall"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "opsgenie", "oncall"),
 		"Who is oncall",
-		[]string{"schedule", "user"}, nil,
+		[]string{"schedule", "user", "person", "team"}, nil,
+	)
+	metrics["RotationOnCall"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "rotation_oncall"),
+		"Who is oncall per schedule rotation",
+		[]string{"schedule", "rotation", "user", "person", "team"}, nil,
+	)
+	metrics["EscalationOnCall"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "escalation_oncall"),
+		"Who is oncall per escalation level of a schedule",
+		[]string{"schedule", "escalation", "level", "user", "person", "team"}, nil,
+	)
+	metrics["NextHandover"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "next_handover_timestamp_seconds"),
+		"The time at which the rotation hands over to the next participant in UTC epoch seconds",
+		[]string{"schedule", "rotation"}, nil,
+	)
+	metrics["GapHours"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "schedule_gap_hours"),
+		"Hours nobody is oncall within the lookahead days",
+		[]string{"schedule"}, nil,
+	)
+	metrics["Overrides"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "schedule_overrides"),
+		"Total number of overrides within the lookahead days",
+		[]string{"schedule"}, nil,
+	)
+	metrics["ActiveOverrides"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "schedule_active_overrides"),
+		"Total number of overrides currently in effect",
+		[]string{"schedule"}, nil,
 	)
 	metrics["UnAckedAlerts"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "opsgenie", "unacked_alerts"),
 		"Total number of unacked alerts",
-		[]string{"schedule"}, nil,
+		[]string{}, nil,
 	)
 	metrics["AckedAlerts"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "opsgenie", "acked_alerts"),
 		"Total number of acked alerts",
-		[]string{"schedule"}, nil,
+		[]string{}, nil,
 	)
 	metrics["ClosedAlerts"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "opsgenie", "closed_alerts"),
 		"Total number of closed alerts",
-		[]string{"schedule"}, nil,
+		[]string{}, nil,
 	)
 
+	metrics["Alerts"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "alerts"),
+		"Total number of alerts by state, priority, owning team, tag and integration source",
+		[]string{"state", "priority", "team", "tag", "source"}, nil,
+	)
+	metrics["AckTime"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "alert_ack_seconds"),
+		"Time to acknowledge alerts closed within the alert window",
+		[]string{"priority", "team"}, nil,
+	)
+	metrics["CloseTime"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "alert_close_seconds"),
+		"Time to close alerts closed within the alert window",
+		[]string{"priority", "team"}, nil,
+	)
+	metrics["Acknowledged"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "alerts_acknowledged"),
+		"Total number of alerts closed within the alert window acknowledged by user",
+		[]string{"user", "person", "team"}, nil,
+	)
+	metrics["OnCallAlerts"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "oncall_alerts"),
+		"Total number of alerts created within the alert window while the user was oncall",
+		[]string{"schedule", "user", "person", "team", "hours"}, nil,
+	)
+	metrics["InterruptedNights"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "oncall_interrupted_nights"),
+		"Total number of nights within the alert window with an alert while the user was oncall",
+		[]string{"schedule", "user", "person", "team"}, nil,
+	)
+	metrics["TeamOnCallAlerts"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "team_oncall_alerts"),
+		"Total number of alerts created within the alert window while a team member was oncall",
+		[]string{"hours", "team"}, nil,
+	)
+	metrics["TeamInterruptedNights"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "team_oncall_interrupted_nights"),
+		"Total number of nights within the alert window with an alert while a team member was oncall",
+		[]string{"team"}, nil,
+	)
+	metrics["TeamAcknowledged"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "team_alerts_acknowledged"),
+		"Total number of alerts closed within the alert window acknowledged by team members",
+		[]string{"team"}, nil,
+	)
+	metrics["Incidents"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "incidents"),
+		"Total number of open and resolved incidents, resolved ones are not closed yet",
+		[]string{"status", "priority"}, nil,
+	)
+	metrics["PostmortemPending"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "incidents_postmortem_pending"),
+		"Total number of resolved and closed incidents still tagged as pending a postmortem",
+		[]string{"priority"}, nil,
+	)
+	metrics["IncidentDuration"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "incident_duration_seconds"),
+		"Impact duration of incidents created within the alert window",
+		[]string{"priority"}, nil,
+	)
+	metrics["HeartbeatEnabled"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "heartbeat_enabled"),
+		"Whether the heartbeat is enabled",
+		[]string{"heartbeat", "team"}, nil,
+	)
+	metrics["HeartbeatExpired"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "heartbeat_expired"),
+		"Whether the heartbeat has not been pinged within its interval",
+		[]string{"heartbeat", "team"}, nil,
+	)
+	metrics["IntegrationEnabled"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "opsgenie", "integration_enabled"),
+		"Whether the integration is enabled",
+		[]string{"integration", "type"}, nil,
+	)
+
+	if err := config.Privacy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
 	exporter := &OpsGenieExporter{
-		Metrics:  metrics,
-		apiKey:   apiKey,
-		schedule: schedule,
+		Metrics: metrics,
+		apiKey:  apiKey,
+		config:  config.withDefaults(),
 	}
 
 	// Fetch once so any bugs are triggered on startup
@@ -64,8 +179,85 @@ func (e *OpsGenieExporter) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
-	ch <- prometheus.MustNewConstMetric(e.Metrics["WhosOnCall"], prometheus.GaugeValue, float64(1), e.schedule, q.WhosOnCall)
-	ch <- prometheus.MustNewConstMetric(e.Metrics["UnAckedAlerts"], prometheus.GaugeValue, float64(q.UnAckedAlerts), e.schedule)
-	ch <- prometheus.MustNewConstMetric(e.Metrics["AckedAlerts"], prometheus.GaugeValue, float64(q.AckedAlerts), e.schedule)
-	ch <- prometheus.MustNewConstMetric(e.Metrics["ClosedAlerts"], prometheus.GaugeValue, float64(q.ClosedAlerts), e.schedule)
+	users := e.config.Privacy.NewSeries()
+	for _, o := range q.OnCalls {
+		person, team := e.config.Identities.Lookup(identity.OpsGenie, o.User)
+		users.Add(e.Metrics["WhosOnCall"], prometheus.GaugeValue, float64(1), o.User, person, o.Schedule, identity.UserLabel, identity.PersonLabel, team)
+	}
+	for _, o := range q.Escalations {
+		person, team := e.config.Identities.Lookup(identity.OpsGenie, o.User)
+		users.Add(e.Metrics["EscalationOnCall"], prometheus.GaugeValue, float64(1), o.User, person, o.Schedule, o.Escalation, strconv.Itoa(o.Level), identity.UserLabel, identity.PersonLabel, team)
+	}
+	for _, r := range q.Rotations {
+		person, team := e.config.Identities.Lookup(identity.OpsGenie, r.User)
+		users.Add(e.Metrics["RotationOnCall"], prometheus.GaugeValue, float64(1), r.User, person, r.Schedule, r.Rotation, identity.UserLabel, identity.PersonLabel, team)
+	}
+	for _, h := range q.Handovers {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["NextHandover"], prometheus.GaugeValue, float64(h.At.Unix()), h.Schedule, h.Rotation)
+	}
+	for _, c := range q.Coverage {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["GapHours"], prometheus.GaugeValue, c.GapHours, c.Schedule)
+		ch <- prometheus.MustNewConstMetric(e.Metrics["Overrides"], prometheus.GaugeValue, float64(c.Overrides), c.Schedule)
+		ch <- prometheus.MustNewConstMetric(e.Metrics["ActiveOverrides"], prometheus.GaugeValue, float64(c.ActiveOverrides), c.Schedule)
+	}
+	ch <- prometheus.MustNewConstMetric(e.Metrics["UnAckedAlerts"], prometheus.GaugeValue, float64(q.UnAckedAlerts))
+	ch <- prometheus.MustNewConstMetric(e.Metrics["AckedAlerts"], prometheus.GaugeValue, float64(q.AckedAlerts))
+	ch <- prometheus.MustNewConstMetric(e.Metrics["ClosedAlerts"], prometheus.GaugeValue, float64(q.ClosedAlerts))
+	for _, g := range q.AlertGroups {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["Alerts"], prometheus.GaugeValue, float64(g.Count), g.State, g.Priority, g.Team, g.Tag, g.Source)
+	}
+	for _, h := range q.AckTimes {
+		ch <- prometheus.MustNewConstHistogram(e.Metrics["AckTime"], h.Count, h.Sum, h.Buckets, h.Priority, h.Team)
+	}
+	for _, h := range q.CloseTimes {
+		ch <- prometheus.MustNewConstHistogram(e.Metrics["CloseTime"], h.Count, h.Sum, h.Buckets, h.Priority, h.Team)
+	}
+	teamAlerts, teamNights, teamAcknowledged := e.config.Privacy.NewSums(), e.config.Privacy.NewSums(), e.config.Privacy.NewSums()
+	for _, l := range q.OnCallLoads {
+		person, team := e.config.Identities.Lookup(identity.OpsGenie, l.User)
+		users.Add(e.Metrics["OnCallAlerts"], prometheus.GaugeValue, float64(l.BusinessHours), l.User, person, l.Schedule, identity.UserLabel, identity.PersonLabel, team, "business")
+		users.Add(e.Metrics["OnCallAlerts"], prometheus.GaugeValue, float64(l.OutOfHours), l.User, person, l.Schedule, identity.UserLabel, identity.PersonLabel, team, "out_of_hours")
+		users.Add(e.Metrics["InterruptedNights"], prometheus.GaugeValue, float64(l.Nights), l.User, person, l.Schedule, identity.UserLabel, identity.PersonLabel, team)
+		teamAlerts.AddTeam(float64(l.BusinessHours), l.User, team, "business")
+		teamAlerts.AddTeam(float64(l.OutOfHours), l.User, team, "out_of_hours")
+		teamNights.AddTeam(float64(l.Nights), l.User, team)
+	}
+	for _, i := range q.Incidents {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["Incidents"], prometheus.GaugeValue, float64(i.Count), i.Status, i.Priority)
+	}
+	for _, i := range q.Postmortems {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["PostmortemPending"], prometheus.GaugeValue, float64(i.Count), i.Priority)
+	}
+	for _, h := range q.Durations {
+		ch <- prometheus.MustNewConstHistogram(e.Metrics["IncidentDuration"], h.Count, h.Sum, h.Buckets, h.Priority)
+	}
+	for _, h := range q.Heartbeats {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["HeartbeatEnabled"], prometheus.GaugeValue, boolToFloat(h.Enabled), h.Name, h.OwnerTeam.Name)
+		ch <- prometheus.MustNewConstMetric(e.Metrics["HeartbeatExpired"], prometheus.GaugeValue, boolToFloat(h.Expired), h.Name, h.OwnerTeam.Name)
+	}
+	for _, i := range q.Integrations {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["IntegrationEnabled"], prometheus.GaugeValue, boolToFloat(i.Enabled), i.Name, i.Type)
+	}
+	for _, r := range q.Acknowledgers {
+		person, team := e.config.Identities.Lookup(identity.OpsGenie, r.User)
+		users.Add(e.Metrics["Acknowledged"], prometheus.GaugeValue, float64(r.Count), r.User, person, identity.UserLabel, identity.PersonLabel, team)
+		teamAcknowledged.AddTeam(float64(r.Count), r.User, team)
+	}
+	users.Collect(ch)
+	teamAlerts.Each(func(v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["TeamOnCallAlerts"], prometheus.GaugeValue, v, labels...)
+	})
+	teamNights.Each(func(v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["TeamInterruptedNights"], prometheus.GaugeValue, v, labels...)
+	})
+	teamAcknowledged.Each(func(v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["TeamAcknowledged"], prometheus.GaugeValue, v, labels...)
+	})
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
 }
@@ -0,0 +1,151 @@
+package opsgenie
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Response time buckets in seconds, from a minute to a day
+var responseBuckets = []float64{60, 300, 900, 1800, 3600, 7200, 14400, 28800, 86400}
+
+// The alert API refuses to page beyond this many alerts
+const maxAlertOffset = 20000
+
+const alertPageSize = 100
+
+type Alert struct {
+	ID           string    `json:"id"`
+	TinyID       string    `json:"tinyId"`
+	Status       string    `json:"status"`
+	Acknowledged bool      `json:"acknowledged"`
+	Priority     string    `json:"priority"`
+	Owner        string    `json:"owner"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	Teams        []struct {
+		ID string `json:"id"`
+	} `json:"teams"`
+	Report struct {
+		AckTime        int64  `json:"ackTime"`
+		CloseTime      int64  `json:"closeTime"`
+		AcknowledgedBy string `json:"acknowledgedBy"`
+		ClosedBy       string `json:"closedBy"`
+	} `json:"report"`
+}
+
+type Team struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Histogram is a cumulative histogram of response times in seconds
+type Histogram struct {
+	Priority string
+	Team     string
+	Count    uint64
+	Sum      float64
+	Buckets  map[float64]uint64
+}
+
+func (h *Histogram) observe(seconds float64) {
+	h.Count++
+	h.Sum += seconds
+	for _, b := range responseBuckets {
+		if seconds <= b {
+			h.Buckets[b]++
+		}
+	}
+}
+
+type Responder struct {
+	User  string
+	Count int
+}
+
+// ListAlerts pages through every alert matching the query, newest first
+func (e *OpsGenieExporter) ListAlerts(ctx context.Context, query string) ([]Alert, error) {
+	alerts := []Alert{}
+	for offset := 0; offset < maxAlertOffset; offset += alertPageSize {
+		page := []Alert{}
+		v := url.Values{}
+		v.Set("query", query)
+		v.Set("offset", strconv.Itoa(offset))
+		v.Set("limit", strconv.Itoa(alertPageSize))
+		v.Set("sort", "createdAt")
+		v.Set("order", "desc")
+
+		err := e.getRequest(ctx, "/v2/alerts?"+v.Encode(), &page)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, page...)
+		if len(page) < alertPageSize {
+			break
+		}
+	}
+	return alerts, nil
+}
+
+func (e *OpsGenieExporter) GetTeams(ctx context.Context) ([]Team, error) {
+	result := []Team{}
+	err := e.getRequest(ctx, "/v2/teams", &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// responseTimes aggregates the time to acknowledge and to close the closed
+// alerts, by priority and owning team
+func responseTimes(alerts []Alert, teamNames map[string]string) (acks, closes []*Histogram, responders []Responder) {
+	ackTimes := map[[2]string]*Histogram{}
+	closeTimes := map[[2]string]*Histogram{}
+	acknowledged := map[string]int{}
+	histogram := func(m map[[2]string]*Histogram, priority, team string) *Histogram {
+		k := [2]string{priority, team}
+		if m[k] == nil {
+			m[k] = &Histogram{Priority: priority, Team: team, Buckets: map[float64]uint64{}}
+		}
+		return m[k]
+	}
+
+	for _, a := range alerts {
+		if a.Status != "closed" {
+			continue
+		}
+
+		// Alerts count towards every team they are assigned to
+		names := []string{}
+		for _, t := range a.Teams {
+			names = append(names, teamNames[t.ID])
+		}
+		if len(names) == 0 {
+			names = append(names, "none")
+		}
+
+		for _, team := range names {
+			if a.Acknowledged && a.Report.AckTime > 0 {
+				histogram(ackTimes, a.Priority, team).observe(float64(a.Report.AckTime) / 1000)
+			}
+			if a.Report.CloseTime > 0 {
+				histogram(closeTimes, a.Priority, team).observe(float64(a.Report.CloseTime) / 1000)
+			}
+		}
+		if a.Report.AcknowledgedBy != "" {
+			acknowledged[a.Report.AcknowledgedBy]++
+		}
+	}
+
+	for _, h := range ackTimes {
+		acks = append(acks, h)
+	}
+	for _, h := range closeTimes {
+		closes = append(closes, h)
+	}
+	for user, count := range acknowledged {
+		responders = append(responders, Responder{User: user, Count: count})
+	}
+	return acks, closes, responders
+}
@@ -0,0 +1,185 @@
+package opsgenie
+
+import (
+	"context"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type Schedule struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Enabled   bool   `json:"enabled"`
+	OwnerTeam struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"ownerTeam"`
+}
+
+type Timeline struct {
+	StartDate     time.Time `json:"startDate"`
+	EndDate       time.Time `json:"endDate"`
+	FinalTimeline struct {
+		Rotations []TimelineRotation `json:"rotations"`
+	} `json:"finalTimeline"`
+	OverrideTimeline struct {
+		Rotations []TimelineRotation `json:"rotations"`
+	} `json:"overrideTimeline"`
+}
+
+type TimelineRotation struct {
+	ID      string           `json:"id"`
+	Name    string           `json:"name"`
+	Periods []TimelinePeriod `json:"periods"`
+}
+
+type TimelinePeriod struct {
+	StartDate time.Time `json:"startDate"`
+	EndDate   time.Time `json:"endDate"`
+	Type      string    `json:"type"`
+	Recipient struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Name string `json:"name"`
+	} `json:"recipient"`
+}
+
+// GetSchedules lists the configured schedules by name and every schedule owned by the configured teams
+func (e *OpsGenieExporter) GetSchedules(ctx context.Context) ([]Schedule, error) {
+	result := []Schedule{}
+	err := e.getRequest(ctx, "/v2/schedules", &result)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.config.Schedules) == 0 && len(e.config.Teams) == 0 {
+		return result, nil
+	}
+
+	names := map[string]bool{}
+	for _, name := range e.config.Schedules {
+		names[name] = true
+	}
+	teams := map[string]bool{}
+	for _, team := range e.config.Teams {
+		teams[team] = true
+	}
+
+	schedules := []Schedule{}
+	for _, s := range result {
+		if names[s.Name] || teams[s.OwnerTeam.Name] {
+			schedules = append(schedules, s)
+		}
+	}
+	return schedules, nil
+}
+
+// GetTimeline returns the final timeline of a schedule, including overrides, for the days from start
+func (e *OpsGenieExporter) GetTimeline(ctx context.Context, scheduleID string, start time.Time, days int) (*Timeline, error) {
+	result := &Timeline{}
+	v := url.Values{}
+	v.Set("identifierType", "id")
+	v.Set("interval", strconv.Itoa(days))
+	v.Set("intervalUnit", "days")
+	v.Set("date", start.Format(time.RFC3339))
+	// Without expanding, the timeline only holds the final rotations
+	v.Set("expand", "override")
+
+	err := e.getRequest(ctx, "/v2/schedules/"+url.PathEscape(scheduleID)+"/timeline?"+v.Encode(), result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// timelineDays returns the days of timeline covering the alert window and at
+// least the month ahead. A window of part of a day takes a whole day, or the
+// timeline would end before the lookahead days and show a gap.
+func timelineDays(window time.Duration, lookaheadDays int) int {
+	if lookaheadDays < 31 {
+		lookaheadDays = 31
+	}
+	return int(math.Ceil(window.Hours()/24)) + lookaheadDays
+}
+
+// current returns the periods of each rotation covering at and the time each one hands over
+func (t *Timeline) current(at time.Time) ([]RotationOnCall, []Handover) {
+	oncalls := []RotationOnCall{}
+	handovers := []Handover{}
+	for _, r := range t.FinalTimeline.Rotations {
+		var next time.Time
+		for _, p := range r.Periods {
+			if !p.StartDate.After(at) && p.EndDate.After(at) {
+				oncalls = append(oncalls, RotationOnCall{Rotation: r.Name, User: p.Recipient.Name})
+				next = p.EndDate
+				break
+			}
+			// Nobody is on call right now, the rotation hands over when the next period starts
+			if p.StartDate.After(at) && (next.IsZero() || p.StartDate.Before(next)) {
+				next = p.StartDate
+			}
+		}
+		if !next.IsZero() {
+			handovers = append(handovers, Handover{Rotation: r.Name, At: next})
+		}
+	}
+	return oncalls, handovers
+}
+
+type Coverage struct {
+	Schedule        string
+	GapHours        float64
+	Overrides       int
+	ActiveOverrides int
+}
+
+// coverage measures the hours nobody is on call and counts the overrides between from and to
+func (t *Timeline) coverage(from, to time.Time) Coverage {
+	type interval struct{ start, end time.Time }
+	intervals := []interval{}
+	for _, r := range t.FinalTimeline.Rotations {
+		for _, p := range r.Periods {
+			start, end := p.StartDate, p.EndDate
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+			if start.Before(end) {
+				intervals = append(intervals, interval{start, end})
+			}
+		}
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].start.Before(intervals[j].start) })
+
+	// Walk the periods in order, any time not yet covered before a period starts is a gap
+	var gaps time.Duration
+	covered := from
+	for _, i := range intervals {
+		if i.start.After(covered) {
+			gaps += i.start.Sub(covered)
+		}
+		if i.end.After(covered) {
+			covered = i.end
+		}
+	}
+	if to.After(covered) {
+		gaps += to.Sub(covered)
+	}
+
+	c := Coverage{GapHours: gaps.Hours()}
+	for _, r := range t.OverrideTimeline.Rotations {
+		for _, p := range r.Periods {
+			if p.StartDate.Before(to) && p.EndDate.After(from) {
+				c.Overrides++
+			}
+			if !p.StartDate.After(from) && p.EndDate.After(from) {
+				c.ActiveOverrides++
+			}
+		}
+	}
+	return c
+}
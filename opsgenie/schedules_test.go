@@ -0,0 +1,100 @@
+package opsgenie
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var day = time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+
+func period(from, to int, recipient string) TimelinePeriod {
+	p := TimelinePeriod{StartDate: day.Add(time.Duration(from) * time.Hour), EndDate: day.Add(time.Duration(to) * time.Hour)}
+	p.Recipient.Name = recipient
+	return p
+}
+
+func timeline(final, overrides []TimelinePeriod) *Timeline {
+	t := &Timeline{}
+	t.FinalTimeline.Rotations = []TimelineRotation{{Name: "primary", Periods: final}}
+	t.OverrideTimeline.Rotations = []TimelineRotation{{Name: "overrides", Periods: overrides}}
+	return t
+}
+
+func TestTimelineDays(t *testing.T) {
+	tests := []struct {
+		window    time.Duration
+		lookahead int
+		want      int
+	}{
+		{7 * 24 * time.Hour, 14, 38},
+		{7 * 24 * time.Hour, 60, 67},
+		{36 * time.Hour, 60, 62},
+		{time.Hour, 31, 32},
+		{0, 0, 31},
+	}
+
+	for _, tt := range tests {
+		if got := timelineDays(tt.window, tt.lookahead); got != tt.want {
+			t.Errorf("timelineDays(%v, %d) = %d, want %d", tt.window, tt.lookahead, got, tt.want)
+		}
+	}
+}
+
+func TestTimelineCoverage(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeline *Timeline
+		from, to int
+		want     Coverage
+	}{
+		{"covered", timeline([]TimelinePeriod{period(0, 12, "a"), period(12, 24, "b")}, nil), 0, 24, Coverage{}},
+		{"gap between periods", timeline([]TimelinePeriod{period(0, 8, "a"), period(10, 24, "b")}, nil), 0, 24, Coverage{GapHours: 2}},
+		{"overlapping periods", timeline([]TimelinePeriod{period(0, 14, "a"), period(10, 24, "b")}, nil), 0, 24, Coverage{}},
+		{"gap at the start", timeline([]TimelinePeriod{period(3, 24, "a")}, nil), 0, 24, Coverage{GapHours: 3}},
+		{"timeline ends early", timeline([]TimelinePeriod{period(0, 20, "a")}, nil), 0, 24, Coverage{GapHours: 4}},
+		{"periods outside the range", timeline([]TimelinePeriod{period(-10, 6, "a"), period(18, 40, "b")}, nil), 0, 24, Coverage{GapHours: 12}},
+		{"nobody on call", timeline(nil, nil), 0, 24, Coverage{GapHours: 24}},
+		{"overrides", timeline([]TimelinePeriod{period(-12, 48, "a")}, []TimelinePeriod{period(-2, 2, "b"), period(6, 8, "c"), period(30, 32, "d")}), 0, 24, Coverage{Overrides: 2, ActiveOverrides: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.timeline.coverage(day.Add(time.Duration(tt.from)*time.Hour), day.Add(time.Duration(tt.to)*time.Hour))
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimelineCurrent(t *testing.T) {
+	tests := []struct {
+		name      string
+		timeline  *Timeline
+		oncalls   []RotationOnCall
+		handovers []Handover
+	}{
+		{"on call", timeline([]TimelinePeriod{period(0, 12, "a"), period(12, 24, "b")}, nil),
+			[]RotationOnCall{{Rotation: "primary", User: "a"}},
+			[]Handover{{Rotation: "primary", At: day.Add(12 * time.Hour)}}},
+		{"nobody on call yet", timeline([]TimelinePeriod{period(8, 12, "a"), period(4, 6, "b")}, nil),
+			[]RotationOnCall{},
+			[]Handover{{Rotation: "primary", At: day.Add(4 * time.Hour)}}},
+		{"nobody on call anymore", timeline([]TimelinePeriod{period(-4, 0, "a")}, nil),
+			[]RotationOnCall{},
+			[]Handover{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oncalls, handovers := tt.timeline.current(day.Add(2 * time.Hour))
+			if !reflect.DeepEqual(oncalls, tt.oncalls) {
+				t.Errorf("got on calls %+v, want %+v", oncalls, tt.oncalls)
+			}
+			if !reflect.DeepEqual(handovers, tt.handovers) {
+				t.Errorf("got handovers %+v, want %+v", handovers, tt.handovers)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package stackoverflow
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Answer time buckets in seconds, from five minutes to a week
+var answerBuckets = []float64{300, 900, 3600, 4 * 3600, 12 * 3600, 86400, 3 * 86400, 7 * 86400}
+
+// Histogram is a cumulative histogram of answer times in seconds
+type Histogram struct {
+	Tag     string
+	Count   uint64
+	Sum     float64
+	Buckets map[float64]uint64
+}
+
+func newHistogram(tag string) *Histogram {
+	return &Histogram{Tag: tag, Buckets: map[float64]uint64{}}
+}
+
+func (h *Histogram) observe(seconds float64) {
+	h.Count++
+	h.Sum += seconds
+	for _, b := range answerBuckets {
+		if seconds <= b {
+			h.Buckets[b]++
+		}
+	}
+}
+
+// GetUnanswered returns the number of questions with the tag without any answer and the creation date of the oldest
+func (e *StackOverflowExporter) GetUnanswered(ctx context.Context, tag string) (int, int64, error) {
+	v := url.Values{}
+	v.Set("filter", "total")
+	v.Set("tagged", tag)
+	resp, err := e.getRequest(ctx, "/questions/no-answers", v, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	oldest := []Question{}
+	v = url.Values{}
+	v.Set("pagesize", "1")
+	v.Set("order", "asc")
+	v.Set("sort", "creation")
+	v.Set("tagged", tag)
+	if _, err := e.getRequest(ctx, "/questions/no-answers", v, &oldest); err != nil {
+		return 0, 0, err
+	}
+	if len(oldest) == 0 {
+		return resp.Total, 0, nil
+	}
+	return resp.Total, oldest[0].Creation_date, nil
+}
+
+// GetAnswers returns every answer to the questions, requesting up to a page of question ids at once
+func (e *StackOverflowExporter) GetAnswers(ctx context.Context, questionIDs []int) ([]Answer, error) {
+	answers := []Answer{}
+	for start := 0; start < len(questionIDs); start += pageSize {
+		end := start + pageSize
+		if end > len(questionIDs) {
+			end = len(questionIDs)
+		}
+		ids := []string{}
+		for _, id := range questionIDs[start:end] {
+			ids = append(ids, strconv.Itoa(id))
+		}
+
+		for page := 1; ; page++ {
+			result := []Answer{}
+			v := url.Values{}
+			v.Set("page", strconv.Itoa(page))
+			v.Set("pagesize", strconv.Itoa(pageSize))
+			v.Set("order", "asc")
+			v.Set("sort", "creation")
+			v.Set("filter", "default")
+
+			resp, err := e.getRequest(ctx, "/questions/"+strings.Join(ids, ";")+"/answers", v, &result)
+			if err != nil {
+				return nil, err
+			}
+			answers = append(answers, result...)
+
+			if !resp.HasMore {
+				break
+			}
+		}
+	}
+	return answers, nil
+}
+
+// answerCache keeps the answers of each question until the question is active
+// again, as the quota doesn't allow requesting every answer on each fetch. Votes
+// don't count as activity, so answer scores are only as recent as the question.
+type answerCache struct {
+	mu   sync.Mutex
+	prev map[int]cachedAnswers
+	next map[int]cachedAnswers
+}
+
+type cachedAnswers struct {
+	activity int64
+	answers  []Answer
+}
+
+func newAnswerCache() *answerCache {
+	return &answerCache{prev: map[int]cachedAnswers{}, next: map[int]cachedAnswers{}}
+}
+
+// rotate forgets the answers of questions not looked up since the previous rotate
+func (c *answerCache) rotate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prev, c.next = c.next, map[int]cachedAnswers{}
+}
+
+// lookup returns the cached answers of the questions and the ids of questions
+// which are new or were active since their answers were cached
+func (c *answerCache) lookup(questions []Question) (answers []Answer, stale []int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, q := range questions {
+		cached, ok := c.next[q.Question_id]
+		if !ok {
+			cached, ok = c.prev[q.Question_id]
+		}
+		if !ok || cached.activity != q.Last_activity_date {
+			stale = append(stale, q.Question_id)
+			continue
+		}
+		c.next[q.Question_id] = cached
+		answers = append(answers, cached.answers...)
+	}
+	return answers, stale
+}
+
+func (c *answerCache) store(questions []Question, stale []int, answers []Answer) {
+	activity := map[int]int64{}
+	for _, q := range questions {
+		activity[q.Question_id] = q.Last_activity_date
+	}
+	byQuestion := map[int][]Answer{}
+	for _, a := range answers {
+		byQuestion[a.Question_id] = append(byQuestion[a.Question_id], a)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range stale {
+		c.next[id] = cachedAnswers{activity: activity[id], answers: byQuestion[id]}
+	}
+}
+
+// getCachedAnswers returns every answer to the questions, only requesting those
+// of questions which are new or were active since the previous fetch
+func (e *StackOverflowExporter) getCachedAnswers(ctx context.Context, questions []Question) ([]Answer, error) {
+	answers, stale := e.answers.lookup(questions)
+	if len(stale) == 0 {
+		return answers, nil
+	}
+	fetched, err := e.GetAnswers(ctx, stale)
+	if err != nil {
+		return nil, err
+	}
+	e.answers.store(questions, stale, fetched)
+	return append(answers, fetched...), nil
+}
+
+// answerTimes aggregates the time from asking a question to its first and to its accepted answer
+func answerTimes(tag string, questionCreation map[int]int64, answers []Answer) (first, accepted *Histogram) {
+	firstAnswer := map[int]int64{}
+	for _, a := range answers {
+		if t, ok := firstAnswer[a.Question_id]; !ok || a.Creation_date < t {
+			firstAnswer[a.Question_id] = a.Creation_date
+		}
+	}
+
+	first, accepted = newHistogram(tag), newHistogram(tag)
+	for id, t := range firstAnswer {
+		if created, ok := questionCreation[id]; ok {
+			first.observe(float64(t - created))
+		}
+	}
+	for _, a := range answers {
+		if created, ok := questionCreation[a.Question_id]; ok && a.Is_accepted {
+			accepted.observe(float64(a.Creation_date - created))
+		}
+	}
+	return first, accepted
+}
@@ -0,0 +1,42 @@
+package stackoverflow
+
+import (
+	"time"
+
+	"github.com/fanatic/team-exporter/identity"
+)
+
+const (
+	DefaultVersion      = "2.2"
+	TeamsBaseURL        = "https://api.stackoverflowteams.com"
+	DefaultTeamsVersion = "2.3"
+)
+
+type Config struct {
+	// Team is the slug of a Stack Overflow for Teams instance, which is
+	// authenticated with AccessToken instead of the public API key
+	Team        string
+	AccessToken string
+	// Version of the API, defaults to the one of the public or Teams API
+	Version string
+
+	// Tags are queried individually, an entry may combine tags separated by
+	// semicolons to query questions tagged with all of them
+	Tags []string
+	// Concurrency bounds the number of tags fetched at once
+	Concurrency int
+	// MinQuota is the remaining daily API quota below which only one tag is
+	// refreshed per fetch, the others keep their previous results
+	MinQuota int
+	// MaxPages limits how many pages of 100 questions are fetched
+	MaxPages int
+	// TopWindow adds top askers and answerers of the last TopWindow with the
+	// custom period, computed from questions and answers as the API only
+	// knows all_time and month
+	TopWindow time.Duration
+
+	// Identities maps Stack Overflow user ids to people, Privacy is how user
+	// ids and display names are exported
+	Identities *identity.Mapper
+	Privacy    identity.Privacy
+}
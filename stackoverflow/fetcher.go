@@ -3,61 +3,102 @@ package stackoverflow
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
 type Query struct {
-	Questions []Owner
-	Askers    []TagUser
-	Answerers []TagUser
+	QuotaMax       int
+	QuotaRemaining int
+	Tags           []TagStats
+	Questions      []Owner
+	Askers         []TagUser
+	Answerers      []TagUser
+	Users          map[int]string
+}
+
+type TagStats struct {
+	Tag              string
+	Total            int
+	Unanswered       int
+	OldestUnanswered int64
+	FirstAnswer      *Histogram
+	AcceptedAnswer   *Histogram
 }
 
 type Owner struct {
-	Tag   string
-	User  string
-	Count int
+	Tag    string
+	UserID int
+	Count  int
 }
 
 type TagUser struct {
-	Tag   string
-	User  string
-	Score int
-	Count int
+	Tag    string
+	Period string
+	UserID int
+	Score  int
+	Count  int
 }
 
 func (e *StackOverflowExporter) Fetch(ctx context.Context) error {
 	log.WithFields(log.Fields{"ref": "stack-overflow.fetch", "at": "start"}).Info()
 	startTime := time.Now()
 
-	q := &Query{}
-
-	questionsByOwner, _, err := e.GetQuestions(ctx)
-	if err != nil {
-		return err
-	}
-	for owner, questions := range questionsByOwner {
-		q.Questions = append(q.Questions, Owner{Tag: e.tag, User: owner, Count: len(questions)})
+	// Once the quota runs low only the tag refreshed longest ago is fetched,
+	// the others keep their previous results until the quota is reset
+	tags := e.config.Tags
+	max, remaining := e.quota.values()
+	if max > 0 && remaining < e.config.MinQuota && len(e.config.Tags) > 0 && len(e.tagResults) == len(e.config.Tags) {
+		tags = []string{e.stalestTag()}
+		log.WithFields(log.Fields{"ref": "stack-overflow.fetch", "at": "low-quota", "quota-remaining": remaining, "tag": tags[0]}).Warn()
+	} else {
+		e.answers.rotate()
 	}
 
-	topAskers, err := e.GetTopAskers(ctx)
-	if err != nil {
-		return err
+	// Tags share the API quota, so only a few are fetched at once
+	results := make([]*Query, len(tags))
+	errs := make([]error, len(tags))
+	sem := make(chan struct{}, e.config.Concurrency)
+	var wg sync.WaitGroup
+	for i, tag := range tags {
+		wg.Add(1)
+		go func(i int, tag string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = e.fetchTag(ctx, tag)
+		}(i, tag)
 	}
-	for _, asker := range topAskers {
-		q.Askers = append(q.Askers, TagUser{Tag: e.tag, User: asker.User.Display_name, Score: asker.Score, Count: asker.Post_count})
-	}
-	topAnswerers, err := e.GetTopAnswerers(ctx)
-	if err != nil {
-		return err
+	wg.Wait()
+
+	for i, tag := range tags {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		e.tagResults[tag] = tagResult{query: results[i], fetched: startTime}
 	}
-	for _, answerer := range topAnswerers {
-		q.Answerers = append(q.Answerers, TagUser{Tag: e.tag, User: answerer.User.Display_name, Score: answerer.Score, Count: answerer.Post_count})
+
+	q := &Query{Users: map[int]string{}}
+	for _, tag := range e.config.Tags {
+		r := e.tagResults[tag].query
+		q.Tags = append(q.Tags, r.Tags...)
+		q.Questions = append(q.Questions, r.Questions...)
+		q.Askers = append(q.Askers, r.Askers...)
+		q.Answerers = append(q.Answerers, r.Answerers...)
+		for id, name := range r.Users {
+			q.Users[id] = name
+		}
 	}
 
+	q.QuotaMax, q.QuotaRemaining = e.quota.values()
+
 	e.resultCache = q
 
 	log.WithFields(log.Fields{"ref": "stack-overflow.fetch", "at": "finish", "duration": time.Since(startTime)}).Info()
@@ -65,88 +106,205 @@ func (e *StackOverflowExporter) Fetch(ctx context.Context) error {
 
 }
 
-func (e *StackOverflowExporter) GetQuestions(ctx context.Context) (map[string][]int, map[int]int64, error) {
-	result := []Question{}
-	v := url.Values{}
-	v.Set("page", "1")
-	v.Set("order", "desc")
-	v.Set("sort", "activity")
-	v.Set("filter", "default")
-	v.Set("tagged", e.tag)
+// tagResult is the result of the last fetch of a tag
+type tagResult struct {
+	query   *Query
+	fetched time.Time
+}
 
-	err := e.getRequest(ctx, "/2.2/questions", v, &result)
+// stalestTag returns the tag whose result was fetched longest ago
+func (e *StackOverflowExporter) stalestTag() string {
+	stalest := e.config.Tags[0]
+	for _, tag := range e.config.Tags {
+		if e.tagResults[tag].fetched.Before(e.tagResults[stalest].fetched) {
+			stalest = tag
+		}
+	}
+	return stalest
+}
+
+// fetchTag queries a single tag, or a combination of tags separated by semicolons
+func (e *StackOverflowExporter) fetchTag(ctx context.Context, tag string) (*Query, error) {
+	q := &Query{Users: map[int]string{}}
+	stats := TagStats{Tag: tag}
+
+	total, err := e.GetQuestionsTotal(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+	stats.Total = total
+
+	stats.Unanswered, stats.OldestUnanswered, err = e.GetUnanswered(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	questionsByOwner, questions, err := e.GetQuestions(ctx, tag, q.Users)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	for owner, ids := range questionsByOwner {
+		q.Questions = append(q.Questions, Owner{Tag: tag, UserID: owner, Count: len(ids)})
 	}
 
-	questionsByOwner := map[string][]int{}
 	questionCreation := map[int]int64{}
+	for _, question := range questions {
+		questionCreation[question.Question_id] = question.Creation_date
+	}
+	answers, err := e.getCachedAnswers(ctx, questions)
+	if err != nil {
+		return nil, err
+	}
+	stats.FirstAnswer, stats.AcceptedAnswer = answerTimes(tag, questionCreation, answers)
+	q.Tags = append(q.Tags, stats)
+
+	// Top users are only available for a single tag
+	if strings.Contains(tag, ";") {
+		return q, nil
+	}
 
-	for _, q := range result {
-		questionsByOwner[q.Owner.Display_name] = append(questionsByOwner[q.Owner.Display_name], q.Question_id)
-		questionCreation[q.Question_id] = q.Creation_date
+	for _, period := range topPeriods {
+		topAskers, err := e.GetTopAskers(ctx, tag, period)
+		if err != nil {
+			return nil, err
+		}
+		q.addTopUsers(&q.Askers, tag, period, topAskers)
+		topAnswerers, err := e.GetTopAnswerers(ctx, tag, period)
+		if err != nil {
+			return nil, err
+		}
+		q.addTopUsers(&q.Answerers, tag, period, topAnswerers)
+	}
+	if e.config.TopWindow > 0 {
+		topAskers, topAnswerers, err := e.GetTopUsersSince(ctx, tag, time.Now().Add(-e.config.TopWindow))
+		if err != nil {
+			return nil, err
+		}
+		q.addTopUsers(&q.Askers, tag, "custom", topAskers)
+		q.addTopUsers(&q.Answerers, tag, "custom", topAnswerers)
 	}
 
-	return questionsByOwner, questionCreation, nil
+	return q, nil
 }
 
-func (e *StackOverflowExporter) GetTopAskers(ctx context.Context) ([]TagScore, error) {
-	result := []TagScore{}
-	v := url.Values{}
-	err := e.getRequest(ctx, "/2.2/tags/"+e.tag+"/top-askers/all_time", v, &result)
-	if err != nil {
-		return nil, err
+// GetQuestions returns the question ids by owner user id and the questions,
+// recording the display name of each owner in users
+func (e *StackOverflowExporter) GetQuestions(ctx context.Context, tag string, users map[int]string) (map[int][]int, []Question, error) {
+	questionsByOwner := map[int][]int{}
+	questions := []Question{}
+
+	for page := 1; page <= e.config.MaxPages; page++ {
+		result := []Question{}
+		v := url.Values{}
+		v.Set("page", strconv.Itoa(page))
+		v.Set("pagesize", strconv.Itoa(pageSize))
+		v.Set("order", "desc")
+		v.Set("sort", "activity")
+		v.Set("filter", "default")
+		v.Set("tagged", tag)
+
+		resp, err := e.getRequest(ctx, "/questions", v, &result)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, q := range result {
+			// Unregistered users have no user id and are counted together under 0
+			questionsByOwner[q.Owner.User_id] = append(questionsByOwner[q.Owner.User_id], q.Question_id)
+			if q.Owner.User_id != 0 {
+				users[q.Owner.User_id] = q.Owner.Display_name
+			}
+		}
+		questions = append(questions, result...)
+
+		if !resp.HasMore {
+			break
+		}
 	}
-	return result, nil
+
+	return questionsByOwner, questions, nil
 }
 
-func (e *StackOverflowExporter) GetTopAnswerers(ctx context.Context) ([]TagScore, error) {
-	result := []TagScore{}
+// GetQuestionsTotal returns the number of questions with the tag as counted by the API
+func (e *StackOverflowExporter) GetQuestionsTotal(ctx context.Context, tag string) (int, error) {
 	v := url.Values{}
-	err := e.getRequest(ctx, "/2.2/tags/"+e.tag+"/top-answerers/all_time", v, &result)
+	v.Set("filter", "total")
+	v.Set("tagged", tag)
+
+	resp, err := e.getRequest(ctx, "/questions", v, nil)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	return result, nil
+	return resp.Total, nil
 }
 
-func (e *StackOverflowExporter) getRequest(ctx context.Context, path string, v url.Values, b interface{}) error {
-	//log.WithFields(log.Fields{"ref": "stackoverflow.get-request", "at": "start", "url": e.baseURL + path + "?" + v.Encode()}).Info()
+// The API caps pagesize at 100
+const pageSize = 100
+
+type response struct {
+	Items          json.RawMessage `json:"items"`
+	ErrorId        int             `json:"error_id"`
+	ErrorName      string          `json:"error_name"`
+	ErrorMessage   string          `json:"error_message"`
+	Backoff        int             `json:"backoff"`
+	HasMore        bool            `json:"has_more"`
+	Page           int             `json:"page"`
+	Page_size      int             `json:"page_size"`
+	QuotaMax       int             `json:"quota_max"`
+	QuotaRemaining int             `json:"quota_remaining"`
+	Total          int             `json:"total"`
+	Type           string          `json:"type"`
+}
+
+func (e *StackOverflowExporter) getRequest(ctx context.Context, path string, v url.Values, b interface{}) (*response, error) {
+	//log.WithFields(log.Fields{"ref": "stackoverflow.get-request", "at": "start", "url": e.baseURL + "/" + e.config.Version + path + "?" + v.Encode()}).Info()
+	// The API asks to wait before requesting the same method again
+	method := methodOf(path)
+	if err := e.quota.wait(ctx, method); err != nil {
+		return nil, err
+	}
 	startTime := time.Now()
 
-	v.Set("key", e.apiKey)
-	req, err := http.NewRequest("GET", e.baseURL+path+"?"+v.Encode(), nil)
+	if e.apiKey != "" {
+		v.Set("key", e.apiKey)
+	}
+	if e.config.Team != "" {
+		v.Set("team", e.config.Team)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", e.baseURL+"/"+e.config.Version+path+"?"+v.Encode(), nil)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if e.config.AccessToken != "" {
+		req.Header.Set("X-API-Access-Token", e.config.AccessToken)
 	}
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	result := struct {
-		Items          json.RawMessage `json:"items"`
-		ErrorId        int             `json:"error_id"`
-		ErrorName      string          `json:"error_name"`
-		ErrorMessage   string          `json:"error_message"`
-		Backoff        int             `json:"backoff"`
-		HasMore        bool            `json:"has_more"`
-		Page           int             `json:"page"`
-		Page_size      int             `json:"page_size"`
-		QuotaMax       int             `json:"quota_max"`
-		QuotaRemaining int             `json:"quota_remaining"`
-		Total          int             `json:"total"`
-		Type           string          `json:"type"`
-	}{}
-	err = json.NewDecoder(resp.Body).Decode(&result)
+	result := &response{}
+	err = json.NewDecoder(resp.Body).Decode(result)
 	if err != nil {
-		return err
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return nil, fmt.Errorf("stackoverflow: GET %s: %s", path, resp.Status)
+		}
+		return nil, err
 	}
+	e.quota.update(method, result)
 
-	log.WithFields(log.Fields{"ref": "stackoverflow.get-request", "at": "finish", "status": resp.StatusCode, "quota-max": result.QuotaMax, "quota-remaining": result.QuotaRemaining, "has-more": result.HasMore, "duration": time.Since(startTime)}).Info()
+	log.WithFields(log.Fields{"ref": "stackoverflow.get-request", "at": "finish", "status": resp.StatusCode, "quota-max": result.QuotaMax, "quota-remaining": result.QuotaRemaining, "has-more": result.HasMore, "backoff": result.Backoff, "duration": time.Since(startTime)}).Info()
 
-	return json.Unmarshal(result.Items, b)
+	if result.ErrorId != 0 {
+		return nil, fmt.Errorf("stackoverflow: GET %s: %s: %s (%d)", path, result.ErrorName, result.ErrorMessage, result.ErrorId)
+	}
+
+	// Filters like total return no items
+	if b == nil || result.Items == nil {
+		return result, nil
+	}
+	return result, json.Unmarshal(result.Items, b)
 }
 
 type Question struct {
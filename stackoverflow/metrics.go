@@ -2,51 +2,136 @@ package stackoverflow
 
 import (
 	"context"
+	"strconv"
+	"time"
 
+	"github.com/fanatic/team-exporter/identity"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 type StackOverflowExporter struct {
 	Metrics     map[string]*prometheus.Desc
 	apiKey      string
-	tag         string
 	baseURL     string
+	config      Config
+	quota       *quota
+	answers     *answerCache
+	tagResults  map[string]tagResult
 	resultCache *Query
 }
 
-func New(baseURL, apiKey, tag string) (*StackOverflowExporter, error) {
+func New(baseURL, apiKey string, config Config) (*StackOverflowExporter, error) {
 	metrics := map[string]*prometheus.Desc{}
 	metrics["QuestionsTotal"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "stackoverflow", "questions_total"),
 		"Total number of questions",
-		[]string{"tag", "owner"}, nil,
+		[]string{"tag", "user_id", "person", "team"}, nil,
+	)
+	metrics["TagQuestions"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "stackoverflow", "tag_questions_total"),
+		"Total number of questions with the tag as reported by the API",
+		[]string{"tag"}, nil,
+	)
+	metrics["Unanswered"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "stackoverflow", "unanswered_questions"),
+		"Total number of questions with the tag without any answer",
+		[]string{"tag"}, nil,
+	)
+	metrics["OldestUnanswered"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "stackoverflow", "oldest_unanswered_question_age_seconds"),
+		"Age of the oldest question with the tag without any answer",
+		[]string{"tag"}, nil,
+	)
+	metrics["FirstAnswer"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "stackoverflow", "first_answer_seconds"),
+		"Time from asking a question to its first answer",
+		[]string{"tag"}, nil,
+	)
+	metrics["AcceptedAnswer"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "stackoverflow", "accepted_answer_seconds"),
+		"Time from asking a question to its accepted answer",
+		[]string{"tag"}, nil,
 	)
 	metrics["AskerScore"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "stackoverflow", "asker_score"),
 		"Total user score for questions",
-		[]string{"tag", "user"}, nil,
+		[]string{"tag", "period", "user_id", "person", "team"}, nil,
 	)
 	metrics["AskerPostCount"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "stackoverflow", "asker_post_count"),
 		"Total number of questions by user",
-		[]string{"tag", "user"}, nil,
+		[]string{"tag", "period", "user_id", "person", "team"}, nil,
 	)
 	metrics["AnswererScore"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "stackoverflow", "answerer_score"),
 		"Total user score for answers",
-		[]string{"tag", "user"}, nil,
+		[]string{"tag", "period", "user_id", "person", "team"}, nil,
 	)
 	metrics["AnswererPostCount"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "stackoverflow", "answerer_post_count"),
 		"Total number of answers by user",
-		[]string{"tag", "user"}, nil,
+		[]string{"tag", "period", "user_id", "person", "team"}, nil,
+	)
+	metrics["TeamQuestions"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "stackoverflow", "team_questions_total"),
+		"Total number of questions by team members",
+		[]string{"tag", "team"}, nil,
+	)
+	metrics["TeamAnswererScore"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "stackoverflow", "team_answerer_score"),
+		"Total score for answers of team members among the top answerers",
+		[]string{"tag", "period", "team"}, nil,
+	)
+	metrics["TeamAnswererPostCount"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "stackoverflow", "team_answerer_post_count"),
+		"Total number of answers by team members among the top answerers",
+		[]string{"tag", "period", "team"}, nil,
+	)
+	metrics["UserInfo"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "stackoverflow", "user_info"),
+		"Display name of each user, which users can change",
+		[]string{"user_id", "display_name"}, nil,
+	)
+	metrics["QuotaMax"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "stackoverflow", "quota_max"),
+		"Number of API requests allowed per day",
+		[]string{}, nil,
 	)
+	metrics["QuotaRemaining"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "stackoverflow", "quota_remaining"),
+		"Number of API requests remaining today",
+		[]string{}, nil,
+	)
+
+	if err := config.Privacy.Validate(); err != nil {
+		return nil, err
+	}
+	if config.MaxPages < 1 {
+		config.MaxPages = 1
+	}
+	if config.Concurrency < 1 {
+		config.Concurrency = 1
+	}
+	if config.Team != "" {
+		if baseURL == "" {
+			baseURL = TeamsBaseURL
+		}
+		if config.Version == "" {
+			config.Version = DefaultTeamsVersion
+		}
+	}
+	if config.Version == "" {
+		config.Version = DefaultVersion
+	}
 
 	exporter := &StackOverflowExporter{
-		Metrics: metrics,
-		baseURL: baseURL,
-		apiKey:  apiKey,
-		tag:     tag,
+		Metrics:    metrics,
+		baseURL:    baseURL,
+		apiKey:     apiKey,
+		config:     config,
+		quota:      newQuota(),
+		answers:    newAnswerCache(),
+		tagResults: map[string]tagResult{},
 	}
 
 	// Fetch once so any bugs are triggered on startup
@@ -71,15 +156,50 @@ func (e *StackOverflowExporter) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
+	ch <- prometheus.MustNewConstMetric(e.Metrics["QuotaMax"], prometheus.GaugeValue, float64(q.QuotaMax))
+	ch <- prometheus.MustNewConstMetric(e.Metrics["QuotaRemaining"], prometheus.GaugeValue, float64(q.QuotaRemaining))
+	for _, t := range q.Tags {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["TagQuestions"], prometheus.GaugeValue, float64(t.Total), t.Tag)
+		ch <- prometheus.MustNewConstMetric(e.Metrics["Unanswered"], prometheus.GaugeValue, float64(t.Unanswered), t.Tag)
+		if t.OldestUnanswered > 0 {
+			ch <- prometheus.MustNewConstMetric(e.Metrics["OldestUnanswered"], prometheus.GaugeValue, time.Since(time.Unix(t.OldestUnanswered, 0)).Seconds(), t.Tag)
+		}
+		ch <- prometheus.MustNewConstHistogram(e.Metrics["FirstAnswer"], t.FirstAnswer.Count, t.FirstAnswer.Sum, t.FirstAnswer.Buckets, t.Tag)
+		ch <- prometheus.MustNewConstHistogram(e.Metrics["AcceptedAnswer"], t.AcceptedAnswer.Count, t.AcceptedAnswer.Sum, t.AcceptedAnswer.Buckets, t.Tag)
+	}
+	users := e.config.Privacy.NewSeries()
+	teamQuestions, teamScore, teamAnswers := e.config.Privacy.NewSums(), e.config.Privacy.NewSums(), e.config.Privacy.NewSums()
 	for _, q := range q.Questions {
-		ch <- prometheus.MustNewConstMetric(e.Metrics["QuestionsTotal"], prometheus.GaugeValue, float64(q.Count), q.Tag, q.User)
+		id := strconv.Itoa(q.UserID)
+		person, team := e.config.Identities.Lookup(identity.StackOverflow, id)
+		users.Add(e.Metrics["QuestionsTotal"], prometheus.GaugeValue, float64(q.Count), id, person, q.Tag, identity.UserLabel, identity.PersonLabel, team)
+		teamQuestions.AddTeam(float64(q.Count), id, team, q.Tag)
 	}
 	for _, u := range q.Askers {
-		ch <- prometheus.MustNewConstMetric(e.Metrics["AskerScore"], prometheus.GaugeValue, float64(u.Score), u.Tag, u.User)
-		ch <- prometheus.MustNewConstMetric(e.Metrics["AskerPostCount"], prometheus.GaugeValue, float64(u.Count), u.Tag, u.User)
+		id := strconv.Itoa(u.UserID)
+		person, team := e.config.Identities.Lookup(identity.StackOverflow, id)
+		users.Add(e.Metrics["AskerScore"], prometheus.GaugeValue, float64(u.Score), id, person, u.Tag, u.Period, identity.UserLabel, identity.PersonLabel, team)
+		users.Add(e.Metrics["AskerPostCount"], prometheus.GaugeValue, float64(u.Count), id, person, u.Tag, u.Period, identity.UserLabel, identity.PersonLabel, team)
+	}
+	for id, name := range q.Users {
+		users.Info(e.Metrics["UserInfo"], strconv.Itoa(id), name, identity.UserLabel, identity.PersonLabel)
 	}
 	for _, u := range q.Answerers {
-		ch <- prometheus.MustNewConstMetric(e.Metrics["AnswererScore"], prometheus.GaugeValue, float64(u.Score), u.Tag, u.User)
-		ch <- prometheus.MustNewConstMetric(e.Metrics["AnswererPostCount"], prometheus.GaugeValue, float64(u.Count), u.Tag, u.User)
+		id := strconv.Itoa(u.UserID)
+		person, team := e.config.Identities.Lookup(identity.StackOverflow, id)
+		users.Add(e.Metrics["AnswererScore"], prometheus.GaugeValue, float64(u.Score), id, person, u.Tag, u.Period, identity.UserLabel, identity.PersonLabel, team)
+		users.Add(e.Metrics["AnswererPostCount"], prometheus.GaugeValue, float64(u.Count), id, person, u.Tag, u.Period, identity.UserLabel, identity.PersonLabel, team)
+		teamScore.AddTeam(float64(u.Score), id, team, u.Tag, u.Period)
+		teamAnswers.AddTeam(float64(u.Count), id, team, u.Tag, u.Period)
 	}
+	users.Collect(ch)
+	teamQuestions.Each(func(v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["TeamQuestions"], prometheus.GaugeValue, v, labels...)
+	})
+	teamScore.Each(func(v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["TeamAnswererScore"], prometheus.GaugeValue, v, labels...)
+	})
+	teamAnswers.Each(func(v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["TeamAnswererPostCount"], prometheus.GaugeValue, v, labels...)
+	})
 }
@@ -0,0 +1,72 @@
+package stackoverflow
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Question and answer ids in a path, like 1;2;3
+var idsPattern = regexp.MustCompile(`^[0-9;]+$`)
+
+// quota tracks the remaining API quota and the backoff the API asked for per method
+type quota struct {
+	mu        sync.Mutex
+	max       int
+	remaining int
+	notBefore map[string]time.Time
+}
+
+func newQuota() *quota {
+	return &quota{notBefore: map[string]time.Time{}}
+}
+
+// wait blocks until the backoff of the method has passed
+func (q *quota) wait(ctx context.Context, method string) error {
+	q.mu.Lock()
+	until := q.notBefore[method]
+	q.mu.Unlock()
+	return sleep(ctx, time.Until(until))
+}
+
+func (q *quota) update(method string, r *response) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if r.QuotaMax > 0 {
+		q.max, q.remaining = r.QuotaMax, r.QuotaRemaining
+	}
+	if r.Backoff > 0 {
+		q.notBefore[method] = time.Now().Add(time.Duration(r.Backoff) * time.Second)
+	}
+}
+
+func (q *quota) values() (max, remaining int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.max, q.remaining
+}
+
+// methodOf strips ids and tags from a path, since backoff applies to the API method as a whole
+func methodOf(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if idsPattern.MatchString(p) || (i == 2 && parts[1] == "tags") {
+			parts[i] = "{}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,120 @@
+package stackoverflow
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Periods of the top users the API reports
+var topPeriods = []string{"all_time", "month"}
+
+// The API reports the top 20 users, which the custom window follows
+const topUsers = 20
+
+func (q *Query) addTopUsers(users *[]TagUser, tag, period string, top []TagScore) {
+	for _, u := range top {
+		q.Users[u.User.User_id] = u.User.Display_name
+		*users = append(*users, TagUser{Tag: tag, Period: period, UserID: u.User.User_id, Score: u.Score, Count: u.Post_count})
+	}
+}
+
+func (e *StackOverflowExporter) GetTopAskers(ctx context.Context, tag, period string) ([]TagScore, error) {
+	result := []TagScore{}
+	v := url.Values{}
+	_, err := e.getRequest(ctx, "/tags/"+url.PathEscape(tag)+"/top-askers/"+period, v, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (e *StackOverflowExporter) GetTopAnswerers(ctx context.Context, tag, period string) ([]TagScore, error) {
+	result := []TagScore{}
+	v := url.Values{}
+	_, err := e.getRequest(ctx, "/tags/"+url.PathEscape(tag)+"/top-answerers/"+period, v, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetTopUsersSince computes the top askers and answerers of questions with the
+// tag since the time. Answers are looked up on questions active since then, as
+// answers can't be searched by tag.
+func (e *StackOverflowExporter) GetTopUsersSince(ctx context.Context, tag string, since time.Time) (askers, answerers []TagScore, err error) {
+	questions := []Question{}
+	for page := 1; page <= e.config.MaxPages; page++ {
+		result := []Question{}
+		v := url.Values{}
+		v.Set("page", strconv.Itoa(page))
+		v.Set("pagesize", strconv.Itoa(pageSize))
+		v.Set("order", "desc")
+		v.Set("sort", "activity")
+		v.Set("min", strconv.FormatInt(since.Unix(), 10))
+		v.Set("filter", "default")
+		v.Set("tagged", tag)
+
+		resp, err := e.getRequest(ctx, "/questions", v, &result)
+		if err != nil {
+			return nil, nil, err
+		}
+		questions = append(questions, result...)
+
+		if !resp.HasMore {
+			break
+		}
+	}
+
+	askerScores := map[int]*TagScore{}
+	for _, q := range questions {
+		if q.Creation_date >= since.Unix() {
+			addScore(askerScores, q.Owner, q.Score)
+		}
+	}
+
+	answers, err := e.getCachedAnswers(ctx, questions)
+	if err != nil {
+		return nil, nil, err
+	}
+	answererScores := map[int]*TagScore{}
+	for _, a := range answers {
+		if a.Creation_date >= since.Unix() {
+			addScore(answererScores, a.Owner, a.Score)
+		}
+	}
+
+	return topScores(askerScores), topScores(answererScores), nil
+}
+
+func addScore(scores map[int]*TagScore, user ShallowUser, score int) {
+	// Unregistered users have no user id and can't be told apart
+	if user.User_id == 0 {
+		return
+	}
+	if scores[user.User_id] == nil {
+		scores[user.User_id] = &TagScore{User: user}
+	}
+	scores[user.User_id].Score += score
+	scores[user.User_id].Post_count++
+}
+
+// topScores orders users by score and post count like the API does
+func topScores(scores map[int]*TagScore) []TagScore {
+	top := []TagScore{}
+	for _, s := range scores {
+		top = append(top, *s)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Score != top[j].Score {
+			return top[i].Score > top[j].Score
+		}
+		return top[i].Post_count > top[j].Post_count
+	})
+	if len(top) > topUsers {
+		top = top[:topUsers]
+	}
+	return top
+}
@@ -0,0 +1,144 @@
+package trello
+
+import (
+	"regexp"
+
+	"github.com/adlio/trello"
+	"github.com/fanatic/team-exporter/identity"
+)
+
+type Config struct {
+	// StateFile persists the cumulative flow cursor, leave empty to keep it in memory only
+	StateFile string
+
+	// Boards are always exported, by ID
+	Boards []string
+	// Organizations exports every board of the given workspaces, by ID or name
+	Organizations []string
+	// BoardNames narrows the organization boards (or the token owner's boards
+	// when no organization is set) down to names matching the expression
+	BoardNames string
+	// ExcludeBoards and ExcludeBoardNames drop boards from any of the selections above
+	ExcludeBoards     []string
+	ExcludeBoardNames string
+
+	IgnoreClosedBoards  bool
+	IgnoreArchivedLists bool
+
+	// DueSoonDays is how far ahead a due date counts as due soon
+	DueSoonDays int
+
+	// NumberFields are summed and DropdownFields counted per value, by custom field name
+	NumberFields   []string
+	DropdownFields []string
+
+	// WIPLimits take precedence over limits parsed from list names like "Doing [3]"
+	WIPLimits []WIPLimit
+
+	// Concurrency bounds the number of boards fetched at once
+	Concurrency int
+
+	// WebhookURL is the public URL of the exporter's webhook endpoint. When set
+	// webhooks are registered for the selected boards to update cards between fetches.
+	WebhookURL string
+	// AppSecret verifies the signature of webhook callbacks and is required with WebhookURL
+	AppSecret string
+
+	// Identities maps Trello usernames to people, Privacy is how usernames are exported
+	Identities *identity.Mapper
+	Privacy    identity.Privacy
+}
+
+// boardSelector picks the boards to export, falling back to every board of the token owner
+type boardSelector struct {
+	config       Config
+	names        *regexp.Regexp
+	excludeNames *regexp.Regexp
+	exclude      map[string]bool
+}
+
+func newBoardSelector(config Config) (*boardSelector, error) {
+	s := &boardSelector{config: config, exclude: map[string]bool{}}
+
+	var err error
+	if config.BoardNames != "" {
+		if s.names, err = regexp.Compile(config.BoardNames); err != nil {
+			return nil, err
+		}
+	}
+	if config.ExcludeBoardNames != "" {
+		if s.excludeNames, err = regexp.Compile(config.ExcludeBoardNames); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range config.ExcludeBoards {
+		s.exclude[id] = true
+	}
+	return s, nil
+}
+
+func (s *boardSelector) boardArgs() trello.Arguments {
+	args := trello.Arguments{"fields": "id,name,closed,idOrganization", "lists": "all", "list_fields": "id,name,closed"}
+	if s.config.IgnoreArchivedLists {
+		args["lists"] = "open"
+	}
+	return args
+}
+
+func (s *boardSelector) boardsArgs() trello.Arguments {
+	args := s.boardArgs()
+	args["filter"] = "all"
+	if s.config.IgnoreClosedBoards {
+		args["filter"] = "open"
+	}
+	return args
+}
+
+func (s *boardSelector) Select(client *trello.Client) ([]*trello.Board, error) {
+	candidates := []*trello.Board{}
+
+	// Boards of workspaces or of the token owner are narrowed down by name
+	paths := []string{}
+	for _, org := range s.config.Organizations {
+		paths = append(paths, "organizations/"+org+"/boards")
+	}
+	if len(paths) == 0 && (len(s.config.Boards) == 0 || s.names != nil) {
+		paths = append(paths, "members/me/boards")
+	}
+	for _, path := range paths {
+		boards := []*trello.Board{}
+		if err := client.Get(path, s.boardsArgs(), &boards); err != nil {
+			return nil, err
+		}
+		for _, board := range boards {
+			if s.names == nil || s.names.MatchString(board.Name) {
+				candidates = append(candidates, board)
+			}
+		}
+	}
+
+	for _, id := range s.config.Boards {
+		board := &trello.Board{}
+		if err := client.Get("boards/"+id, s.boardArgs(), board); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, board)
+	}
+
+	seen := map[string]bool{}
+	selected := []*trello.Board{}
+	for _, board := range candidates {
+		if seen[board.ID] || s.exclude[board.ID] {
+			continue
+		}
+		if s.excludeNames != nil && s.excludeNames.MatchString(board.Name) {
+			continue
+		}
+		if s.config.IgnoreClosedBoards && board.Closed {
+			continue
+		}
+		seen[board.ID] = true
+		selected = append(selected, board)
+	}
+	return selected, nil
+}
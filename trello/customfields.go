@@ -0,0 +1,152 @@
+package trello
+
+import (
+	"strconv"
+
+	"github.com/adlio/trello"
+)
+
+type CustomField struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Options []struct {
+		ID    string `json:"id"`
+		Value struct {
+			Text string `json:"text"`
+		} `json:"value"`
+	} `json:"options"`
+}
+
+type CustomFieldItem struct {
+	IDCustomField string            `json:"idCustomField"`
+	IDValue       string            `json:"idValue"`
+	Value         map[string]string `json:"value"`
+}
+
+type FieldSum struct {
+	Board string
+	List  string
+	User  string
+	Field string
+	Sum   float64
+}
+
+type FieldValueCount struct {
+	Board string
+	List  string
+	User  string
+	Field string
+	Value string
+	Count int
+}
+
+func getCustomFields(client *trello.Client, board *trello.Board) ([]*CustomField, error) {
+	fields := []*CustomField{}
+	err := client.Get("boards/"+board.ID+"/customFields", trello.Arguments{}, &fields)
+	return fields, err
+}
+
+// customFieldAggregator sums the configured number fields and counts the
+// configured dropdown values of a single board
+type customFieldAggregator struct {
+	board      string
+	numbers    map[string]string
+	options    map[string]map[string]string
+	dropdown   map[string]string
+	sums       map[[3]string]float64
+	listSums   map[[2]string]float64
+	counts     map[[4]string]int
+	listCounts map[[3]string]int
+}
+
+func newCustomFieldAggregator(config Config, board *trello.Board, fields []*CustomField) *customFieldAggregator {
+	a := &customFieldAggregator{
+		board:      board.Name,
+		numbers:    map[string]string{},
+		options:    map[string]map[string]string{},
+		dropdown:   map[string]string{},
+		sums:       map[[3]string]float64{},
+		listSums:   map[[2]string]float64{},
+		counts:     map[[4]string]int{},
+		listCounts: map[[3]string]int{},
+	}
+
+	numberNames := map[string]bool{}
+	for _, name := range config.NumberFields {
+		numberNames[name] = true
+	}
+	dropdownNames := map[string]bool{}
+	for _, name := range config.DropdownFields {
+		dropdownNames[name] = true
+	}
+
+	for _, field := range fields {
+		switch {
+		case field.Type == "number" && numberNames[field.Name]:
+			a.numbers[field.ID] = field.Name
+		case field.Type == "list" && dropdownNames[field.Name]:
+			a.dropdown[field.ID] = field.Name
+			a.options[field.ID] = map[string]string{}
+			for _, option := range field.Options {
+				a.options[field.ID][option.ID] = option.Value.Text
+			}
+		}
+	}
+	return a
+}
+
+// addCard sums and counts the fields of a card once, however many members it has
+func (a *customFieldAggregator) addCard(list string, items []CustomFieldItem) {
+	for _, item := range items {
+		if name, ok := a.numbers[item.IDCustomField]; ok {
+			n, err := strconv.ParseFloat(item.Value["number"], 64)
+			if err != nil {
+				continue
+			}
+			a.listSums[[2]string{list, name}] += n
+		}
+		if name, ok := a.dropdown[item.IDCustomField]; ok {
+			value, ok := a.options[item.IDCustomField][item.IDValue]
+			if !ok {
+				continue
+			}
+			a.listCounts[[3]string{list, name, value}]++
+		}
+	}
+}
+
+// add sums and counts the fields of a card towards one of its members
+func (a *customFieldAggregator) add(list, user string, items []CustomFieldItem) {
+	for _, item := range items {
+		if name, ok := a.numbers[item.IDCustomField]; ok {
+			n, err := strconv.ParseFloat(item.Value["number"], 64)
+			if err != nil {
+				continue
+			}
+			a.sums[[3]string{list, user, name}] += n
+		}
+		if name, ok := a.dropdown[item.IDCustomField]; ok {
+			value, ok := a.options[item.IDCustomField][item.IDValue]
+			if !ok {
+				continue
+			}
+			a.counts[[4]string{list, user, name, value}]++
+		}
+	}
+}
+
+func (a *customFieldAggregator) results(q *Query) {
+	for k, sum := range a.sums {
+		q.FieldSums = append(q.FieldSums, FieldSum{Board: a.board, List: k[0], User: k[1], Field: k[2], Sum: sum})
+	}
+	for k, sum := range a.listSums {
+		q.ListFieldSums = append(q.ListFieldSums, FieldSum{Board: a.board, List: k[0], Field: k[1], Sum: sum})
+	}
+	for k, count := range a.counts {
+		q.FieldValues = append(q.FieldValues, FieldValueCount{Board: a.board, List: k[0], User: k[1], Field: k[2], Value: k[3], Count: count})
+	}
+	for k, count := range a.listCounts {
+		q.ListFieldValues = append(q.ListFieldValues, FieldValueCount{Board: a.board, List: k[0], Field: k[1], Value: k[2], Count: count})
+	}
+}
@@ -2,6 +2,7 @@ package trello
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/adlio/trello"
@@ -11,70 +12,261 @@ import (
 func (e *TrelloExporter) Fetch(ctx context.Context) error {
 	log.WithFields(log.Fields{"ref": "trello.fetch", "at": "start"}).Info()
 	startTime := time.Now()
+	e.transport.reset()
 
-	client := trello.NewClient(e.appKey, e.token)
-	me, err := client.GetMember("me", trello.Defaults())
+	boards, err := e.boards.Select(e.client)
 	if err != nil {
 		return err
 	}
 
-	boards, err := me.GetBoards(map[string]string{"filter": "open", "lists": "open", "list_fields": "id,name"})
-	if err != nil {
+	// Fetch boards with bounded concurrency, the transport backs off when rate limited
+	models := make([]*boardModel, len(boards))
+	sem := make(chan struct{}, e.config.Concurrency)
+	var wg sync.WaitGroup
+	for i, board := range boards {
+		wg.Add(1)
+		go func(i int, board *trello.Board) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			models[i] = e.fetchBoard(ctx, board)
+		}(i, board)
+	}
+	wg.Wait()
+	for _, m := range models {
+		if m.err != nil {
+			return m.err
+		}
+	}
+
+	if e.webhooks.Load() {
+		e.ensureWebhooks(boards)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, m := range models {
+		e.flow.apply(m.board, m.actions)
+		m.actions = nil
+	}
+	if err := e.flow.save(e.config.StateFile); err != nil {
 		return err
 	}
+	e.models = models
+	e.apiCalls, e.rateLimited = e.transport.reset()
+	e.rebuild(startTime)
+
+	log.WithFields(log.Fields{"ref": "trello.fetch", "at": "finish", "api-calls": e.apiCalls, "duration": time.Since(startTime)}).Info()
+	return nil
+}
+
+// boardModel is the in-memory copy of a board which webhooks update between fetches
+type boardModel struct {
+	board   *trello.Board
+	cards   []*Card
+	fields  []*CustomField
+	actions []*trello.Action
+	err     error
+}
+
+func (e *TrelloExporter) fetchBoard(ctx context.Context, board *trello.Board) *boardModel {
+	//log.WithFields(log.Fields{"ref": "trello.fetch", "at": "start", "board": board.Name}).Info()
+	r := &boardModel{board: board}
+	if r.err = ctx.Err(); r.err != nil {
+		return r
+	}
 
-	q := []Query{}
-	for _, board := range boards {
-		//log.WithFields(log.Fields{"ref": "trello.fetch", "at": "start", "board": board.Name}).Info()
-		memberListCardCount := map[string]map[string]int{}
-		listNames := map[string]string{}
-		cards, err := board.GetCards(map[string]string{"fields": "idList", "members": "true", "member_fields": "username", "filter": "visible"})
-		if err != nil {
-			return err
+	r.cards = []*Card{}
+	r.err = e.client.Get("boards/"+board.ID+"/cards", trello.Arguments{"fields": "idList,labels,due,dueComplete,badges", "members": "true", "member_fields": "username", "customFieldItems": "true", "filter": "visible"}, &r.cards)
+	if r.err != nil {
+		return r
+	}
+
+	// Only look up custom field definitions when some are aggregated
+	if len(e.config.NumberFields) > 0 || len(e.config.DropdownFields) > 0 {
+		if r.fields, r.err = getCustomFields(e.client, board); r.err != nil {
+			return r
 		}
+	}
+
+	// Count cards entering and leaving lists since the last fetch
+	r.actions, r.err = getFlowActions(e.client, board, e.flow.Since[board.ID])
+	return r
+}
 
-		// Aggregate cards per list per member
-		for _, list := range board.Lists {
-			listNames[list.ID] = list.Name
+// rebuild summarizes the board models into a new result, the caller must hold e.mu
+func (e *TrelloExporter) rebuild(now time.Time) {
+	q := &Query{}
+	boards := []*trello.Board{}
+	for _, m := range e.models {
+		e.summarize(q, m.board, m.fields, m.cards, now)
+		boards = append(boards, m.board)
+	}
+	q.Flow = e.flow.snapshot(boards)
+	q.APICalls, q.RateLimited = e.apiCalls, e.rateLimited
+	e.resultCache = q
+}
+
+// summarize aggregates the cards of a board into the query
+func (e *TrelloExporter) summarize(q *Query, board *trello.Board, fields []*CustomField, cards []*Card, now time.Time) {
+	memberListCardCount := map[string]map[string]int{}
+	listCardCount := map[string]int{}
+	memberDueCount := map[string]*DueCount{}
+	labelCount := map[string]int{}
+	listChecklists := map[string]*ChecklistProgress{}
+	customFields := newCustomFieldAggregator(e.config, board, fields)
+	listNames := map[string]string{}
+	dueSoon := now.AddDate(0, 0, e.config.DueSoonDays)
+
+	// Aggregate cards per list per member
+	for _, list := range board.Lists {
+		listNames[list.ID] = list.Name
+	}
+	for _, card := range cards {
+		listName, ok := listNames[card.IDList]
+		if !ok {
+			// Card sits on an archived list which is ignored
+			continue
 		}
-		for _, card := range cards {
-			listName := listNames[card.IDList]
-			for _, member := range card.Members {
-				if memberListCardCount[member.Username] == nil {
-					memberListCardCount[member.Username] = map[string]int{}
-				}
-				memberListCardCount[member.Username][listName]++
+		listCardCount[listName]++
+		customFields.addCard(listName, card.CustomFieldItems)
+
+		usernames := []string{}
+		for _, member := range card.Members {
+			usernames = append(usernames, member.Username)
+		}
+		// Remember to count cards unassociated, but not twice so we can still aggregate
+		if len(usernames) == 0 {
+			usernames = append(usernames, "none")
+		}
+
+		for _, username := range usernames {
+			if memberListCardCount[username] == nil {
+				memberListCardCount[username] = map[string]int{}
 			}
-			// Remember to count cards unassociated, but not twice so we can still aggregate
-			if len(card.Members) == 0 {
-				if memberListCardCount["none"] == nil {
-					memberListCardCount["none"] = map[string]int{}
-				}
-				memberListCardCount["none"][listName]++
+			memberListCardCount[username][listName]++
+			customFields.add(listName, username, card.CustomFieldItems)
+
+			if card.Due == nil || card.DueComplete {
+				continue
+			}
+			if memberDueCount[username] == nil {
+				memberDueCount[username] = &DueCount{Board: board.Name, User: username}
+			}
+			if card.Due.Before(now) {
+				memberDueCount[username].Overdue++
+			} else if card.Due.Before(dueSoon) {
+				memberDueCount[username].DueSoon++
+			}
+		}
+
+		for _, label := range card.Labels {
+			// Labels may be colour only
+			name := label.Name
+			if name == "" {
+				name = label.Color
 			}
+			labelCount[name]++
 		}
 
-		// Transform counts for prometheus
-		for username, lists := range memberListCardCount {
-			for name, count := range lists {
-				q = append(q, Query{
-					Board: board.Name,
-					List:  name,
-					User:  username,
-					Count: count,
-				})
+		if card.Badges.CheckItems > 0 {
+			if listChecklists[listName] == nil {
+				listChecklists[listName] = &ChecklistProgress{Board: board.Name, List: listName}
 			}
+			listChecklists[listName].Items += card.Badges.CheckItems
+			listChecklists[listName].Checked += card.Badges.CheckItemsChecked
 		}
 	}
 
-	e.resultCache = q
-	log.WithFields(log.Fields{"ref": "trello.fetch", "at": "finish", "duration": time.Since(startTime)}).Info()
-	return nil
+	// Transform counts for prometheus
+	for username, lists := range memberListCardCount {
+		for name, count := range lists {
+			q.Cards = append(q.Cards, CardCount{
+				Board: board.Name,
+				List:  name,
+				User:  username,
+				Count: count,
+			})
+		}
+	}
+	for _, d := range memberDueCount {
+		q.Due = append(q.Due, *d)
+	}
+	for name, count := range labelCount {
+		q.Labels = append(q.Labels, LabelCount{Board: board.Name, Label: name, Count: count})
+	}
+	for _, c := range listChecklists {
+		q.Checklists = append(q.Checklists, *c)
+	}
+	customFields.results(q)
+
+	// Lists without cards still report their limit. Cards are counted by list
+	// name, so lists sharing a name share their limit too.
+	wipLists := map[string]bool{}
+	for _, list := range board.Lists {
+		if list.Closed || wipLists[list.Name] {
+			continue
+		}
+		wipLists[list.Name] = true
+		if limit, ok := e.config.wipLimit(board.Name, list.Name); ok {
+			q.WIP = append(q.WIP, ListWIP{Board: board.Name, List: list.Name, Limit: limit, Count: listCardCount[list.Name]})
+		}
+	}
+}
+
+// Card holds the card fields which are aggregated
+type Card struct {
+	ID          string           `json:"id"`
+	IDList      string           `json:"idList"`
+	Due         *time.Time       `json:"due"`
+	DueComplete bool             `json:"dueComplete"`
+	Labels      []*trello.Label  `json:"labels"`
+	Members     []*trello.Member `json:"members"`
+	Badges      struct {
+		CheckItems        int `json:"checkItems"`
+		CheckItemsChecked int `json:"checkItemsChecked"`
+	} `json:"badges"`
+	CustomFieldItems []CustomFieldItem `json:"customFieldItems"`
 }
 
 type Query struct {
+	Cards           []CardCount
+	Due             []DueCount
+	Labels          []LabelCount
+	Checklists      []ChecklistProgress
+	FieldSums       []FieldSum
+	ListFieldSums   []FieldSum
+	FieldValues     []FieldValueCount
+	ListFieldValues []FieldValueCount
+	WIP             []ListWIP
+	Flow            []ListFlow
+	APICalls        int
+	RateLimited     int
+}
+
+type CardCount struct {
 	Board string
 	List  string
 	User  string
 	Count int
 }
+
+type DueCount struct {
+	Board   string
+	User    string
+	Overdue int
+	DueSoon int
+}
+
+type LabelCount struct {
+	Board string
+	Label string
+	Count int
+}
+
+type ChecklistProgress struct {
+	Board   string
+	List    string
+	Items   int
+	Checked int
+}
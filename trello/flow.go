@@ -0,0 +1,193 @@
+package trello
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/adlio/trello"
+)
+
+// Board actions which move a card into or out of a list
+const flowActionFilter = "updateCard:idList,createCard,moveCardToBoard"
+
+// Trello caps the number of actions returned per request
+const actionPageSize = 1000
+
+type ListFlow struct {
+	Board   string
+	List    string
+	Entered int
+	Left    int
+}
+
+// flowStateVersion is bumped when the counters are keyed differently, older
+// state is discarded and the flow counted again from the first action
+const flowStateVersion = 2
+
+// flowState holds the cumulative flow counters per board and list id and the
+// action cursor per board id. It is persisted to disk so restarts neither reset
+// nor double-count flow.
+type flowState struct {
+	Version int                               `json:"version"`
+	Since   map[string]string                 `json:"since"`
+	Flow    map[string]map[string]*flowCounts `json:"flow"`
+}
+
+type flowCounts struct {
+	Entered int `json:"entered"`
+	Left    int `json:"left"`
+}
+
+func newFlowState() *flowState {
+	return &flowState{
+		Version: flowStateVersion,
+		Since:   map[string]string{},
+		Flow:    map[string]map[string]*flowCounts{},
+	}
+}
+
+func loadFlowState(path string) (*flowState, error) {
+	state := newFlowState()
+	if path == "" {
+		return state, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, err
+	}
+	if state.Version != flowStateVersion {
+		return newFlowState(), nil
+	}
+	if state.Since == nil {
+		state.Since = map[string]string{}
+	}
+	if state.Flow == nil {
+		state.Flow = map[string]map[string]*flowCounts{}
+	}
+	return state, nil
+}
+
+// save writes the state to a temporary file first so a crash never leaves a truncated cursor behind
+func (s *flowState) save(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *flowState) list(boardID, listID string) *flowCounts {
+	if s.Flow[boardID] == nil {
+		s.Flow[boardID] = map[string]*flowCounts{}
+	}
+	if s.Flow[boardID][listID] == nil {
+		s.Flow[boardID][listID] = &flowCounts{}
+	}
+	return s.Flow[boardID][listID]
+}
+
+// apply counts card movements of a board, oldest action first, and advances the board's cursor
+func (s *flowState) apply(board *trello.Board, actions []*trello.Action) {
+	if len(actions) == 0 {
+		return
+	}
+
+	for i := len(actions) - 1; i >= 0; i-- {
+		action := actions[i]
+		if action.Data == nil {
+			continue
+		}
+		switch action.Type {
+		case "createCard", "moveCardToBoard":
+			if action.Data.List != nil {
+				s.list(board.ID, action.Data.List.ID).Entered++
+			}
+		case "updateCard":
+			if action.Data.ListBefore != nil {
+				s.list(board.ID, action.Data.ListBefore.ID).Left++
+			}
+			if action.Data.ListAfter != nil {
+				s.list(board.ID, action.Data.ListAfter.ID).Entered++
+			}
+		}
+	}
+
+	// Actions are returned newest first
+	s.Since[board.ID] = actions[0].ID
+}
+
+// snapshot returns the flow of the lists currently on the boards by their
+// current names, so renamed lists keep a single series and boards no longer
+// selected are left out. Lists sharing a name share their series.
+func (s *flowState) snapshot(boards []*trello.Board) []ListFlow {
+	flow := []ListFlow{}
+	index := map[[2]string]int{}
+	for _, board := range boards {
+		for _, list := range board.Lists {
+			counts, ok := s.Flow[board.ID][list.ID]
+			if !ok {
+				continue
+			}
+			key := [2]string{board.Name, list.Name}
+			i, ok := index[key]
+			if !ok {
+				i = len(flow)
+				index[key] = i
+				flow = append(flow, ListFlow{Board: board.Name, List: list.Name})
+			}
+			flow[i].Entered += counts.Entered
+			flow[i].Left += counts.Left
+		}
+	}
+	return flow
+}
+
+// getFlowActions pages through every card movement on the board since the last processed action
+func getFlowActions(client *trello.Client, board *trello.Board, since string) ([]*trello.Action, error) {
+	args := trello.Arguments{
+		"filter": flowActionFilter,
+		"fields": "type,date,data",
+		"limit":  strconv.Itoa(actionPageSize),
+	}
+	if since != "" {
+		args["since"] = since
+	}
+
+	actions := []*trello.Action{}
+	for {
+		page := []*trello.Action{}
+		if err := client.Get("boards/"+board.ID+"/actions", args, &page); err != nil {
+			return nil, err
+		}
+		actions = append(actions, page...)
+		if len(page) < actionPageSize {
+			return actions, nil
+		}
+		args["before"] = page[len(page)-1].ID
+	}
+}
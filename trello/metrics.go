@@ -2,7 +2,13 @@ package trello
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
 
+	"github.com/adlio/trello"
+	"github.com/fanatic/team-exporter/identity"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -10,21 +16,147 @@ type TrelloExporter struct {
 	Metrics     map[string]*prometheus.Desc
 	appKey      string
 	token       string
-	resultCache []Query
+	config      Config
+	client      *trello.Client
+	transport   *transport
+	boards      *boardSelector
+	flow        *flowState
+	resultCache *Query
+
+	// mu guards the board models and result which webhooks update between fetches
+	mu          sync.Mutex
+	models      []*boardModel
+	apiCalls    int
+	rateLimited int
+
+	// webhooks is set once the webhook endpoint is served, so fetches keep them registered
+	webhooks atomic.Bool
 }
 
-func New(appKey, token string) (*TrelloExporter, error) {
+func New(appKey, token string, config Config) (*TrelloExporter, error) {
 	metrics := map[string]*prometheus.Desc{}
 	metrics["CardCount"] = prometheus.NewDesc(
 		prometheus.BuildFQName("team", "trello", "cards"),
 		"Total number of cards",
-		[]string{"board", "list", "user"}, nil,
+		[]string{"board", "list", "user", "person", "team"}, nil,
+	)
+	metrics["TeamCardCount"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "team_cards"),
+		"Total number of cards of the team members, a card counts once per assigned member",
+		[]string{"board", "list", "team"}, nil,
+	)
+	metrics["TeamOverdueCards"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "team_overdue_cards"),
+		"Total number of incomplete cards of the team members past their due date",
+		[]string{"board", "team"}, nil,
+	)
+	metrics["LabelCards"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "label_cards"),
+		"Total number of cards with a label",
+		[]string{"board", "label"}, nil,
+	)
+	metrics["OverdueCards"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "overdue_cards"),
+		"Total number of incomplete cards past their due date",
+		[]string{"board", "user", "person", "team"}, nil,
+	)
+	metrics["DueSoonCards"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "due_soon_cards"),
+		"Total number of incomplete cards due within the configured number of days",
+		[]string{"board", "user", "person", "team"}, nil,
+	)
+	metrics["ChecklistCompletion"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "checklist_completion_ratio"),
+		"Ratio of checked checklist items to all checklist items",
+		[]string{"board", "list"}, nil,
+	)
+	metrics["CustomFieldSum"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "custom_field_sum"),
+		"Sum of a numeric custom field over cards of the member, a card counts towards each of its members",
+		[]string{"board", "list", "user", "person", "team", "field"}, nil,
+	)
+	metrics["ListCustomFieldSum"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "list_custom_field_sum"),
+		"Sum of a numeric custom field over cards in a list, each card counted once",
+		[]string{"board", "list", "field"}, nil,
+	)
+	metrics["CustomFieldCards"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "custom_field_cards"),
+		"Total number of cards of the member with a dropdown custom field value, a card counts towards each of its members",
+		[]string{"board", "list", "user", "person", "team", "field", "value"}, nil,
+	)
+	metrics["ListCustomFieldCards"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "list_custom_field_cards"),
+		"Total number of cards in a list with a dropdown custom field value, each card counted once",
+		[]string{"board", "list", "field", "value"}, nil,
+	)
+	metrics["WIPLimit"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "wip_limit"),
+		"Maximum number of cards allowed in a list",
+		[]string{"board", "list"}, nil,
+	)
+	metrics["WIPCards"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "wip_cards"),
+		"Total number of cards in a list with a WIP limit",
+		[]string{"board", "list"}, nil,
+	)
+	metrics["WIPViolation"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "wip_violation"),
+		"Whether a list holds more cards than its WIP limit",
+		[]string{"board", "list"}, nil,
+	)
+	metrics["APICalls"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "api_calls"),
+		"Number of Trello API calls during the last fetch",
+		[]string{}, nil,
+	)
+	metrics["APIRateLimited"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "api_rate_limited"),
+		"Number of Trello API calls rejected by the rate limit during the last fetch",
+		[]string{}, nil,
+	)
+	metrics["CardsEntered"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "list_cards_entered_total"),
+		"Total number of cards which entered a list",
+		[]string{"board", "list"}, nil,
+	)
+	metrics["CardsLeft"] = prometheus.NewDesc(
+		prometheus.BuildFQName("team", "trello", "list_cards_left_total"),
+		"Total number of cards which left a list",
+		[]string{"board", "list"}, nil,
 	)
 
+	if err := config.Privacy.Validate(); err != nil {
+		return nil, err
+	}
+	if config.WebhookURL != "" && config.AppSecret == "" {
+		return nil, fmt.Errorf("trello: webhooks need the app secret to verify callbacks")
+	}
+	boards, err := newBoardSelector(config)
+	if err != nil {
+		return nil, err
+	}
+	flow, err := loadFlowState(config.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Concurrency < 1 {
+		config.Concurrency = 1
+	}
+	transport := newTransport(http.DefaultTransport)
+	client := trello.NewClient(appKey, token)
+	client.Client = &http.Client{Transport: transport}
+
 	exporter := &TrelloExporter{
-		Metrics: metrics,
-		appKey:  appKey,
-		token:   token,
+		Metrics:   metrics,
+		appKey:    appKey,
+		token:     token,
+		config:    config,
+		client:    client,
+		transport: transport,
+		boards:    boards,
+		flow:      flow,
 	}
 
 	// Fetch once so any bugs are triggered on startup
@@ -44,12 +176,67 @@ func (e *TrelloExporter) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect is called when a scrape is peformed on the /metrics page
 func (e *TrelloExporter) Collect(ch chan<- prometheus.Metric) {
-	queries := e.resultCache
-	if queries == nil {
+	e.mu.Lock()
+	q := e.resultCache
+	e.mu.Unlock()
+	if q == nil {
 		return
 	}
 
-	for _, q := range queries {
-		ch <- prometheus.MustNewConstMetric(e.Metrics["CardCount"], prometheus.GaugeValue, float64(q.Count), q.Board, q.List, q.User)
+	ch <- prometheus.MustNewConstMetric(e.Metrics["APICalls"], prometheus.GaugeValue, float64(q.APICalls))
+	ch <- prometheus.MustNewConstMetric(e.Metrics["APIRateLimited"], prometheus.GaugeValue, float64(q.RateLimited))
+
+	users := e.config.Privacy.NewSeries()
+	teamCards, teamOverdue := e.config.Privacy.NewSums(), e.config.Privacy.NewSums()
+	for _, c := range q.Cards {
+		person, team := e.config.Identities.Lookup(identity.Trello, c.User)
+		users.Add(e.Metrics["CardCount"], prometheus.GaugeValue, float64(c.Count), c.User, person, c.Board, c.List, identity.UserLabel, identity.PersonLabel, team)
+		teamCards.AddTeam(float64(c.Count), c.User, team, c.Board, c.List)
+	}
+	for _, d := range q.Due {
+		person, team := e.config.Identities.Lookup(identity.Trello, d.User)
+		users.Add(e.Metrics["OverdueCards"], prometheus.GaugeValue, float64(d.Overdue), d.User, person, d.Board, identity.UserLabel, identity.PersonLabel, team)
+		users.Add(e.Metrics["DueSoonCards"], prometheus.GaugeValue, float64(d.DueSoon), d.User, person, d.Board, identity.UserLabel, identity.PersonLabel, team)
+		teamOverdue.AddTeam(float64(d.Overdue), d.User, team, d.Board)
+	}
+	teamCards.Each(func(v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["TeamCardCount"], prometheus.GaugeValue, v, labels...)
+	})
+	teamOverdue.Each(func(v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["TeamOverdueCards"], prometheus.GaugeValue, v, labels...)
+	})
+	for _, l := range q.Labels {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["LabelCards"], prometheus.GaugeValue, float64(l.Count), l.Board, l.Label)
+	}
+	for _, c := range q.Checklists {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["ChecklistCompletion"], prometheus.GaugeValue, float64(c.Checked)/float64(c.Items), c.Board, c.List)
+	}
+	for _, f := range q.FieldSums {
+		person, team := e.config.Identities.Lookup(identity.Trello, f.User)
+		users.Add(e.Metrics["CustomFieldSum"], prometheus.GaugeValue, f.Sum, f.User, person, f.Board, f.List, identity.UserLabel, identity.PersonLabel, team, f.Field)
+	}
+	for _, f := range q.ListFieldSums {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["ListCustomFieldSum"], prometheus.GaugeValue, f.Sum, f.Board, f.List, f.Field)
+	}
+	for _, f := range q.FieldValues {
+		person, team := e.config.Identities.Lookup(identity.Trello, f.User)
+		users.Add(e.Metrics["CustomFieldCards"], prometheus.GaugeValue, float64(f.Count), f.User, person, f.Board, f.List, identity.UserLabel, identity.PersonLabel, team, f.Field, f.Value)
+	}
+	for _, f := range q.ListFieldValues {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["ListCustomFieldCards"], prometheus.GaugeValue, float64(f.Count), f.Board, f.List, f.Field, f.Value)
+	}
+	users.Collect(ch)
+	for _, w := range q.WIP {
+		violation := 0
+		if w.Count > w.Limit {
+			violation = 1
+		}
+		ch <- prometheus.MustNewConstMetric(e.Metrics["WIPLimit"], prometheus.GaugeValue, float64(w.Limit), w.Board, w.List)
+		ch <- prometheus.MustNewConstMetric(e.Metrics["WIPCards"], prometheus.GaugeValue, float64(w.Count), w.Board, w.List)
+		ch <- prometheus.MustNewConstMetric(e.Metrics["WIPViolation"], prometheus.GaugeValue, float64(violation), w.Board, w.List)
+	}
+	for _, f := range q.Flow {
+		ch <- prometheus.MustNewConstMetric(e.Metrics["CardsEntered"], prometheus.CounterValue, float64(f.Entered), f.Board, f.List)
+		ch <- prometheus.MustNewConstMetric(e.Metrics["CardsLeft"], prometheus.CounterValue, float64(f.Left), f.Board, f.List)
 	}
 }
@@ -0,0 +1,83 @@
+package trello
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxRetries     = 5
+	initialBackoff = time.Second
+)
+
+// transport counts the Trello API calls and retries requests which hit the
+// per-token rate limit with exponential backoff
+type transport struct {
+	base        http.RoundTripper
+	calls       int64
+	rateLimited int64
+}
+
+func newTransport(base http.RoundTripper) *transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		atomic.AddInt64(&t.calls, 1)
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt == maxRetries {
+			return resp, err
+		}
+		// Requests with a body can only be retried when it can be read again
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+		atomic.AddInt64(&t.rateLimited, 1)
+		resp.Body.Close()
+
+		wait := backoff
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(s) * time.Second
+		}
+		log.WithFields(log.Fields{"ref": "trello.transport", "at": "rate-limited", "path": redact(req.URL.Path), "wait": wait}).Warn()
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// Paths like /1/tokens/<token>/webhooks and request URLs carry the API key and token
+var tokenPattern = regexp.MustCompile(`(/tokens/|\b(?:key|token)=)[^/&\s]+`)
+
+// redact removes the API key and token from paths and errors before they are logged
+func redact(s string) string {
+	return tokenPattern.ReplaceAllString(s, "${1}REDACTED")
+}
+
+// reset returns the counts since the last reset
+func (t *transport) reset() (calls, rateLimited int) {
+	return int(atomic.SwapInt64(&t.calls, 0)), int(atomic.SwapInt64(&t.rateLimited, 0))
+}
@@ -0,0 +1,234 @@
+package trello
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/adlio/trello"
+	log "github.com/sirupsen/logrus"
+)
+
+const webhookDescription = "team-exporter"
+
+// EnsureWebhooks registers webhooks for the boards of the last fetch, and on
+// every fetch from then on. Trello checks the callback URL when registering, so
+// call it once the webhook endpoint is served.
+func (e *TrelloExporter) EnsureWebhooks() {
+	if e.config.WebhookURL == "" {
+		return
+	}
+	e.mu.Lock()
+	boards := []*trello.Board{}
+	for _, m := range e.models {
+		boards = append(boards, m.board)
+	}
+	e.mu.Unlock()
+
+	e.webhooks.Store(true)
+	e.ensureWebhooks(boards)
+}
+
+// ensureWebhooks registers a webhook for every selected board and removes the
+// ones of boards no longer selected. Trello verifies the callback URL when
+// registering, so failures are only logged and retried on the next fetch.
+func (e *TrelloExporter) ensureWebhooks(boards []*trello.Board) {
+	webhooks := []*trello.Webhook{}
+	if err := e.client.Get("tokens/"+e.token+"/webhooks", trello.Arguments{}, &webhooks); err != nil {
+		log.WithFields(log.Fields{"ref": "trello.webhook", "at": "list-error", "err": redact(err.Error())}).Warn()
+		return
+	}
+
+	selected := map[string]bool{}
+	for _, board := range boards {
+		selected[board.ID] = true
+	}
+	registered := map[string]bool{}
+	for _, webhook := range webhooks {
+		if webhook.CallbackURL != e.config.WebhookURL {
+			continue
+		}
+		if selected[webhook.IDModel] {
+			registered[webhook.IDModel] = true
+			continue
+		}
+		if err := e.client.Delete("webhooks/"+webhook.ID, trello.Arguments{}, nil); err != nil {
+			log.WithFields(log.Fields{"ref": "trello.webhook", "at": "delete-error", "board": webhook.IDModel, "err": redact(err.Error())}).Warn()
+		}
+	}
+
+	for _, board := range boards {
+		if registered[board.ID] {
+			continue
+		}
+		webhook := &trello.Webhook{}
+		args := trello.Arguments{"idModel": board.ID, "callbackURL": e.config.WebhookURL, "description": webhookDescription}
+		if err := e.client.Post("webhooks", args, webhook); err != nil {
+			log.WithFields(log.Fields{"ref": "trello.webhook", "at": "register-error", "board": board.Name, "err": redact(err.Error())}).Warn()
+			continue
+		}
+		log.WithFields(log.Fields{"ref": "trello.webhook", "at": "registered", "board": board.Name, "webhook": webhook.ID}).Info()
+	}
+}
+
+type webhookAction struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Board struct {
+			ID string `json:"id"`
+		} `json:"board"`
+		Card struct {
+			ID          string     `json:"id"`
+			Closed      bool       `json:"closed"`
+			Due         *time.Time `json:"due"`
+			DueComplete bool       `json:"dueComplete"`
+		} `json:"card"`
+		List      *trello.List           `json:"list"`
+		ListAfter *trello.List           `json:"listAfter"`
+		Label     *trello.Label          `json:"label"`
+		IDMember  string                 `json:"idMember"`
+		Old       map[string]interface{} `json:"old"`
+	} `json:"data"`
+	Member *trello.Member `json:"member"`
+}
+
+// ServeHTTP consumes Trello webhook callbacks
+func (e *TrelloExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodHead:
+		// Trello checks the callback URL exists before creating a webhook
+		w.WriteHeader(http.StatusOK)
+		return
+	case http.MethodPost:
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !validWebhookSignature(e.config.AppSecret, e.config.WebhookURL, body, r.Header.Get("X-Trello-Webhook")) {
+		log.WithFields(log.Fields{"ref": "trello.webhook", "at": "invalid-signature"}).Warn()
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	payload := struct {
+		Action webhookAction `json:"action"`
+	}{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	e.mu.Lock()
+	if e.applyWebhookAction(&payload.Action) {
+		e.rebuild(time.Now())
+	}
+	e.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Trello signs the body followed by the callback URL with the application secret
+func validWebhookSignature(secret, callbackURL string, body []byte, signature string) bool {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	mac.Write([]byte(callbackURL))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// applyWebhookAction updates the card model of a board, the caller must hold e.mu.
+// Actions the model can't follow are left for the next full fetch to reconcile.
+func (e *TrelloExporter) applyWebhookAction(action *webhookAction) bool {
+	var model *boardModel
+	for _, m := range e.models {
+		if m.board.ID == action.Data.Board.ID {
+			model = m
+		}
+	}
+	if model == nil {
+		return false
+	}
+
+	index := -1
+	for i, card := range model.cards {
+		if card.ID == action.Data.Card.ID {
+			index = i
+		}
+	}
+
+	switch action.Type {
+	case "createCard", "copyCard", "moveCardToBoard":
+		if index >= 0 || action.Data.List == nil {
+			return false
+		}
+		model.cards = append(model.cards, &Card{ID: action.Data.Card.ID, IDList: action.Data.List.ID})
+		return true
+	case "deleteCard", "moveCardFromBoard":
+		if index < 0 {
+			return false
+		}
+		model.cards = append(model.cards[:index], model.cards[index+1:]...)
+		return true
+	}
+
+	if index < 0 {
+		return false
+	}
+	card := model.cards[index]
+
+	switch action.Type {
+	case "updateCard":
+		if _, ok := action.Data.Old["closed"]; ok && action.Data.Card.Closed {
+			model.cards = append(model.cards[:index], model.cards[index+1:]...)
+			return true
+		}
+		if action.Data.ListAfter != nil {
+			card.IDList = action.Data.ListAfter.ID
+		}
+		if _, ok := action.Data.Old["due"]; ok {
+			card.Due = action.Data.Card.Due
+		}
+		if _, ok := action.Data.Old["dueComplete"]; ok {
+			card.DueComplete = action.Data.Card.DueComplete
+		}
+		return true
+	case "addMemberToCard":
+		if action.Member == nil {
+			return false
+		}
+		card.Members = append(card.Members, action.Member)
+		return true
+	case "removeMemberFromCard":
+		for i, member := range card.Members {
+			if member.ID == action.Data.IDMember {
+				card.Members = append(card.Members[:i], card.Members[i+1:]...)
+				return true
+			}
+		}
+	case "addLabelToCard":
+		if action.Data.Label == nil {
+			return false
+		}
+		card.Labels = append(card.Labels, action.Data.Label)
+		return true
+	case "removeLabelFromCard":
+		for i, label := range card.Labels {
+			if action.Data.Label != nil && label.ID == action.Data.Label.ID {
+				card.Labels = append(card.Labels[:i], card.Labels[i+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package trello
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Lists may carry their limit in the name, like "Doing [3]"
+var listLimitPattern = regexp.MustCompile(`\[(\d+)\]\s*$`)
+
+// WIPLimit caps the number of cards in a list. An empty board matches lists of every board.
+type WIPLimit struct {
+	Board string
+	List  string
+	Limit int
+}
+
+type ListWIP struct {
+	Board string
+	List  string
+	Limit int
+	Count int
+}
+
+// ParseWIPLimits parses limits in the form "board/list=limit" or "list=limit"
+func ParseWIPLimits(specs []string) ([]WIPLimit, error) {
+	limits := []WIPLimit{}
+	for _, spec := range specs {
+		i := strings.LastIndex(spec, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("trello: invalid WIP limit %q, expected board/list=limit", spec)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(spec[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("trello: invalid WIP limit %q: %v", spec, err)
+		}
+
+		l := WIPLimit{List: strings.TrimSpace(spec[:i]), Limit: limit}
+		if j := strings.Index(l.List, "/"); j >= 0 {
+			l.Board, l.List = strings.TrimSpace(l.List[:j]), strings.TrimSpace(l.List[j+1:])
+		}
+		limits = append(limits, l)
+	}
+	return limits, nil
+}
+
+// wipLimit prefers a configured limit, board specific first, over one parsed from the list name
+func (c Config) wipLimit(board, list string) (int, bool) {
+	limit, found := 0, false
+	for _, l := range c.WIPLimits {
+		if l.List != list {
+			continue
+		}
+		if l.Board == board {
+			return l.Limit, true
+		}
+		if l.Board == "" {
+			limit, found = l.Limit, true
+		}
+	}
+	if found {
+		return limit, true
+	}
+
+	if m := listLimitPattern.FindStringSubmatch(list); m != nil {
+		limit, err := strconv.Atoi(m[1])
+		return limit, err == nil
+	}
+	return 0, false
+}